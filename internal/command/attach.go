@@ -0,0 +1,86 @@
+package command
+
+import (
+	"context"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	options := newExecOptions()
+	cmd := &cobra.Command{
+		Use:   "attach [OPTIONS] CONTAINER [COMMAND] [ARG...]",
+		Short: "Run a command in the kept debug container of a target container",
+		Args:  RequiresMinArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.container = args[0]
+			options.command = args[1:]
+			if err := applyProfile(cmd.Flags(), &options); err != nil {
+				return err
+			}
+			return runAttach(options)
+		},
+	}
+	flags := cmd.Flags()
+	flags.SetInterspersed(false)
+	addClientFlags(flags, &options)
+	addExecFlags(flags, &options)
+	rootCmd.AddCommand(cmd)
+}
+
+func runAttach(options execOptions) error {
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	cli, err := buildCli(ctx, options)
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	options.tty = cli.UseTty(options.noTty)
+
+	options.container, err = cli.FindContainer(options.container)
+	if err != nil {
+		return err
+	}
+	options.env, err = cli.ResolveEnv(options)
+	if err != nil {
+		return err
+	}
+	sidecars, err := cli.FindSidecars(options.container)
+	if err != nil {
+		return err
+	}
+	if len(sidecars) == 0 {
+		return errors.Errorf(
+			"container: `%s` has no kept debug container, create one with `docker-debug --keep`",
+			options.container[:shortContainerIDSize],
+		)
+	}
+	sidecar := sidecars[0]
+	options.rootfs = sidecar.Labels[labelRootfs]
+	// a stopped sidecar is started before anything is exec'd into it
+	if sidecar.State != "running" {
+		startCtx, startCancel := cli.withContent(cli.config.Timeout)
+		err = cli.Client().ContainerStart(startCtx, sidecar.ID, container.StartOptions{})
+		startCancel()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if options.asTarget {
+		options, err = cli.TargetDefaults(options, sidecar.ID)
+		if err != nil {
+			return err
+		}
+	}
+	if len(options.command) == 0 {
+		options.command = cli.DetectShell(sidecar.ID)
+	}
+	return runSession(ctx, cli, options, sidecar.ID)
+}
@@ -0,0 +1,166 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// capturePidFile is written in the debug container filesystem, not in the shared pid namespace
+const capturePidFile = "/tmp/.docker-debug-capture.pid"
+
+type captureOptions struct {
+	execOptions
+	iface    string
+	filter   string
+	write    string
+	count    int
+	snaplen  int
+	duration time.Duration
+}
+
+func init() {
+	options := captureOptions{execOptions: newExecOptions()}
+	cmd := &cobra.Command{
+		Use:   "capture [OPTIONS] CONTAINER",
+		Short: "Capture packets in the target container network namespace to a pcap file",
+		Example: `  docker-debug capture app -i eth0 -f 'port 5432' -w out.pcap
+  docker-debug capture app | wireshark -k -i -`,
+		Args: RequiresMinArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.container = args[0]
+			return runCapture(options)
+		},
+	}
+	flags := cmd.Flags()
+	flags.SetInterspersed(false)
+	flags.StringVar(&options.image, "image", "", "use this image")
+	addClientFlags(flags, &options.execOptions)
+	addImageFlags(flags, &options.execOptions)
+	flags.StringVarP(&options.iface, "interface", "i", "any", "interface to capture on")
+	flags.StringVarP(&options.filter, "filter", "f", "", "pcap filter expression")
+	flags.StringVarP(&options.write, "write", "w", "-", "pcap file to write, - for stdout")
+	flags.IntVar(&options.count, "count", 0, "stop after receiving count packets")
+	flags.IntVarP(&options.snaplen, "snaplen", "s", 0, "snarf snaplen bytes of data from each packet")
+	flags.DurationVarP(&options.duration, "duration", "d", 0, "stop after the duration")
+	rootCmd.AddCommand(cmd)
+}
+
+// command run tcpdump writing pcap to stdout, its pid is saved to stop it later
+func (options captureOptions) command() []string {
+	cmd := []string{"sh", "-c", `echo $$ > "$0" && exec "$@"`, capturePidFile}
+	cmd = append(cmd, "tcpdump", "-U", "-w", "-", "-i", options.iface)
+	if options.count > 0 {
+		cmd = append(cmd, "-c", strconv.Itoa(options.count))
+	}
+	if options.snaplen > 0 {
+		cmd = append(cmd, "-s", strconv.Itoa(options.snaplen))
+	}
+	if options.filter != "" {
+		cmd = append(cmd, options.filter)
+	}
+	return cmd
+}
+
+func runCapture(options captureOptions) error {
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	cli, err := buildCli(ctx, options.execOptions)
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	options.container, err = cli.FindContainer(options.container)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = cli.Out()
+	if options.write != "-" {
+		file, err := os.Create(options.write)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if err = cli.EnsureImage(options.execOptions); err != nil {
+		return err
+	}
+	options.keep = false
+	options.rootfs = rootfsNone
+	options.capAdds = append(options.capAdds, "NET_ADMIN", "NET_RAW")
+	sidecarID, _, err := cli.CreateContainer(options.container, options.execOptions)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := cli.ContainerClean(ctx, sidecarID); err != nil {
+			logrus.Debugf("%+v", err)
+		}
+	}()
+
+	createCtx, createCancel := cli.withContent(cli.config.Timeout)
+	resp, err := cli.client.ContainerExecCreate(createCtx, sidecarID, container.ExecOptions{
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          options.command(),
+	})
+	createCancel()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	attach, err := cli.client.ContainerExecAttach(ctx, resp.ID, container.ExecStartOptions{})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer attach.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(out, cli.Err(), attach.Reader)
+		done <- err
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+	var timeout <-chan time.Time
+	if options.duration > 0 {
+		timeout = time.After(options.duration)
+	}
+
+	select {
+	case err = <-done:
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		return getExecExitStatus(ctx, cli.client, resp.ID)
+	case <-signals:
+	case <-timeout:
+	}
+	// let tcpdump flush and exit
+	if _, err = cli.execOutput(sidecarID, []string{"sh", "-c", fmt.Sprintf(`kill -INT "$(cat %s)"`, capturePidFile)}); err != nil {
+		logrus.Debugf("%+v", err)
+	}
+	select {
+	case err = <-done:
+		return errors.WithStack(err)
+	case <-time.After(cli.config.Timeout):
+		return nil
+	}
+}
@@ -14,14 +14,18 @@ import (
 	"github.com/docker/docker/api/types/filters"
 	dockerImage "github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/mount"
-	"github.com/docker/docker/api/types/strslice"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/api/types/versions"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
 	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/moby/term"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
 	"github.com/zeromake/docker-debug/internal/config"
+	"github.com/zeromake/docker-debug/pkg/connhelper"
 	"github.com/zeromake/docker-debug/pkg/opts"
 	"github.com/zeromake/docker-debug/pkg/stream"
 	"github.com/zeromake/docker-debug/pkg/tty"
@@ -32,6 +36,8 @@ const (
 	certKey = "cert.pem"
 	keyKey  = "key.pem"
 
+	defaultNegotiateTimeout = time.Second * 10
+
 	legacyDefaultDomain = "index.docker.io"
 	defaultDomain       = "docker.io"
 	officialRepoName    = "library"
@@ -60,6 +66,11 @@ type DebugCli struct {
 	client client.APIClient
 	config *config.Config
 	ctx    context.Context
+	// platform of the debug image, set by EnsureImage
+	platform *ocispec.Platform
+	// cachedVersionHost host whose client uses the cached api version,
+	// the cache is dropped when the daemon rejects that version
+	cachedVersionHost string
 }
 
 // NewDebugCli new DebugCli
@@ -118,11 +129,32 @@ func WithClientConfig(dockerConfig *config.DockerConfig) DebugCliOption {
 		if err != nil {
 			return err
 		}
-		clientOpts := []client.Opt{
-			client.WithHost(host),
-			client.WithVersion(dockerConfig.Version),
+		helper, err := connhelper.GetConnectionHelper(host)
+		if err != nil {
+			return err
+		}
+		// a pinned version wins, auto uses the version cached for the host or negotiates it
+		var negotiate bool
+		clientOpts := []client.Opt{}
+		if !config.IsAutoVersion(dockerConfig.Version) {
+			clientOpts = append(clientOpts, client.WithVersion(dockerConfig.Version))
+		} else if cached := config.CachedAPIVersion(dockerConfig.Host); cached != "" {
+			clientOpts = append(clientOpts, client.WithVersion(cached))
+			cli.cachedVersionHost = dockerConfig.Host
+		} else {
+			negotiate = true
+			clientOpts = append(clientOpts, client.WithAPIVersionNegotiation())
+		}
+		if helper != nil {
+			// ssh:// dial through `ssh host docker system dial-stdio`
+			clientOpts = append(clientOpts,
+				client.WithHost(helper.Host),
+				client.WithDialContext(helper.Dialer),
+			)
+		} else {
+			clientOpts = append(clientOpts, client.WithHost(host))
 		}
-		if dockerConfig.TLS {
+		if helper == nil && dockerConfig.TLS {
 			clientOpts = append(clientOpts, client.WithTLSClientConfig(
 				fmt.Sprintf("%s%s%s", dockerConfig.CertDir, config.PathSeparator, caKey),
 				fmt.Sprintf("%s%s%s", dockerConfig.CertDir, config.PathSeparator, certKey),
@@ -134,10 +166,57 @@ func WithClientConfig(dockerConfig *config.DockerConfig) DebugCliOption {
 			return errors.WithStack(err)
 		}
 		cli.client = dockerClient
+		if negotiate {
+			cli.negotiateAPIVersion(dockerConfig.Host)
+		}
 		return nil
 	}
 }
 
+// forgetStaleAPIVersion drop the cached api version when err is the daemon rejecting it,
+// an invalid parameter while the daemon pings with an older api version
+func (cli *DebugCli) forgetStaleAPIVersion(err error) {
+	if cli == nil || cli.cachedVersionHost == "" || !errdefs.IsInvalidParameter(err) {
+		return
+	}
+	// the command context is already cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), defaultNegotiateTimeout)
+	defer cancel()
+	ping, pingErr := cli.client.Ping(ctx)
+	if pingErr != nil {
+		logrus.Debugf("%+v", pingErr)
+		return
+	}
+	if ping.APIVersion == "" || !versions.LessThan(ping.APIVersion, cli.client.ClientVersion()) {
+		return
+	}
+	if err = config.ForgetAPIVersion(cli.cachedVersionHost); err != nil {
+		logrus.Debugf("%+v", err)
+		return
+	}
+	_, _ = fmt.Fprintf(cli.err, "the api version cached for %s is dropped, it is negotiated again on the next run\n", cli.cachedVersionHost)
+}
+
+// negotiateAPIVersion negotiate now to cache the version of the host,
+// on failure the client still negotiates on its first request
+func (cli *DebugCli) negotiateAPIVersion(host string) {
+	timeout := defaultNegotiateTimeout
+	if cli.config != nil && cli.config.Timeout > 0 {
+		timeout = cli.config.Timeout
+	}
+	ctx, cancel := context.WithTimeout(cli.ctx, timeout)
+	defer cancel()
+	ping, err := cli.client.Ping(ctx)
+	if err != nil {
+		logrus.Debugf("negotiate api version: %+v", err)
+		return
+	}
+	cli.client.NegotiateAPIVersionPing(ping)
+	if err = config.CacheAPIVersion(host, cli.client.ClientVersion()); err != nil {
+		logrus.Debugf("cache api version: %+v", err)
+	}
+}
+
 // UserAgent returns the user agent string used for making API requests
 
 // Close cli close
@@ -178,6 +257,19 @@ func (cli *DebugCli) Config() *config.Config {
 	return cli.config
 }
 
+// UseTty reports whether the exec should allocate a pseudo-TTY, falling back
+// to plain streams when stdin or stdout is redirected
+func (cli *DebugCli) UseTty(noTty bool) bool {
+	if noTty {
+		return false
+	}
+	if err := cli.in.CheckTty(true, true); err != nil {
+		logrus.Debugf("%s, disable tty", err)
+		return false
+	}
+	return cli.out.IsTerminal()
+}
+
 // splitDockerDomain splits a repository name to domain and remotename string.
 // If no valid domain is found, the default domain is used. Repository name
 // needs to be already validated before.
@@ -202,9 +294,22 @@ func (cli *DebugCli) PullImage(image string) error {
 	domain, remainder := splitDockerDomain(image)
 	imageName := path.Join(domain, remainder)
 
+	auth, err := cli.RegistryAuth(domain)
+	if err != nil {
+		return err
+	}
+	encodedAuth, err := registry.EncodeAuthConfig(auth)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
 	ctx, cancel := context.WithCancel(cli.ctx)
 	defer cancel()
-	responseBody, err := cli.client.ImagePull(ctx, imageName, dockerImage.PullOptions{})
+	responseBody, err := cli.client.ImagePull(ctx, imageName, dockerImage.PullOptions{
+		RegistryAuth:  encodedAuth,
+		PrivilegeFunc: cli.registryPrivilegeFunc(domain),
+		Platform:      formatPlatform(cli.platform),
+	})
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -214,7 +319,8 @@ func (cli *DebugCli) PullImage(image string) error {
 			logrus.Debugf("%+v", err)
 		}
 	}()
-	return jsonmessage.DisplayJSONMessagesToStream(responseBody, cli.out, nil)
+	// progress goes to stderr like `docker run`, stdout may be piped
+	return jsonmessage.DisplayJSONMessagesToStream(responseBody, stream.NewOutStream(cli.err), nil)
 }
 
 // FindImage find image
@@ -243,42 +349,55 @@ func containerMode(name string) string {
 	return fmt.Sprintf("container:%s", name)
 }
 
-// CreateContainer create new container and attach target container resource
-func (cli *DebugCli) CreateContainer(attachContainer string, options execOptions) (string, error) {
-	var mounts []mount.Mount
+// CreateContainer create new container and attach target container resource,
+// returns the strategy used to expose the target rootfs at MountDir
+func (cli *DebugCli) CreateContainer(attachContainer string, options execOptions) (string, string, error) {
 	ctx, cancel := cli.withContent(cli.config.Timeout)
 	info, err := cli.client.ContainerInspect(ctx, attachContainer)
 	cancel()
 	if err != nil {
-		return "", errors.WithStack(err)
+		return "", "", errors.WithStack(err)
 	}
 	if !info.State.Running {
-		return "", errors.Errorf("container: `%s` is not running", attachContainer)
-	}
-	attachContainer = info.ID
-	mergedDir, ok := info.GraphDriver.Data["MergedDir"]
-	if !ok || mergedDir == "" {
-		return "", fmt.Errorf("container: `%s` not found merged dir", attachContainer)
-	}
-	if cli.config.MountDir != "" {
-		mounts = append(mounts, mount.Mount{
-			Type:   "bind",
-			Source: mergedDir,
-			Target: cli.config.MountDir,
-		})
-		for _, i := range info.Mounts {
-			var mountType = i.Type
-			if i.Type == "volume" {
-				mountType = "bind"
+		return "", "", errors.Errorf("container: `%s` is not running", attachContainer)
+	}
+	candidates, err := rootfsCandidates(options.rootfs, info, cli.config.MountDir)
+	if err != nil {
+		return "", "", err
+	}
+	for i, strategy := range candidates {
+		var id string
+		id, err = cli.startContainer(info, options, strategy)
+		if err != nil {
+			if i+1 < len(candidates) {
+				logrus.Debugf("rootfs strategy %s: %+v", strategy, err)
 			}
-			mounts = append(mounts, mount.Mount{
-				Type:     mountType,
-				Source:   i.Source,
-				Target:   cli.config.MountDir + i.Destination,
-				ReadOnly: !i.RW,
-			})
+			continue
 		}
+		if strategy == rootfsProc {
+			if err = cli.linkProcRoot(id); err != nil {
+				logrus.Debugf("rootfs strategy %s: %+v", strategy, err)
+				strategy = rootfsNone
+			}
+		}
+		// warn only when the filesystem was wanted but every strategy fell back to none
+		if strategy == rootfsNone && options.rootfs != rootfsNone && cli.config.MountDir != "" {
+			_, _ = fmt.Fprintf(
+				cli.err,
+				"WARNING: container `%s` filesystem is not available at %s (storage driver %s), only network and pid namespaces are shared\n",
+				trimContainerName(info.Name),
+				cli.config.MountDir,
+				info.Driver,
+			)
+		}
+		return id, strategy, nil
 	}
+	return "", "", err
+}
+
+func (cli *DebugCli) startContainer(info types.ContainerJSON, options execOptions, strategy string) (string, error) {
+	attachContainer := info.ID
+	mounts := rootfsMounts(strategy, info, cli.config.MountDir)
 	if options.volumes != nil {
 		// -v bind mount
 		if mounts == nil {
@@ -289,7 +408,11 @@ func (cli *DebugCli) CreateContainer(attachContainer string, options execOptions
 			mountLen := len(mountArgs)
 			if mountLen > 0 && mountLen <= 3 {
 				if strings.HasPrefix(mountArgs[0], "$c/") {
-					mountArgs[0] = path.Join(mergedDir, mountArgs[0][11:])
+					source := rootfsSource(strategy, info)
+					if source == "" {
+						return "", errors.Errorf("volume `%s` requires the target rootfs on docker host", m)
+					}
+					mountArgs[0] = path.Join(source, strings.TrimPrefix(mountArgs[0], "$c/"))
 				}
 				mountDefault := mount.Mount{
 					Type:     "bind",
@@ -318,14 +441,16 @@ func (cli *DebugCli) CreateContainer(attachContainer string, options execOptions
 		}
 	}
 	targetName := containerMode(attachContainer)
+	labels := sidecarLabels(info, options)
+	labels[labelRootfs] = strategy
 
 	conf := &container.Config{
-		Entrypoint: strslice.StrSlice([]string{"/usr/bin/env", "sh"}),
 		Image:      cli.config.Image,
 		Tty:        true,
 		OpenStdin:  true,
 		StdinOnce:  true,
 		StopSignal: "SIGKILL",
+		Labels:     labels,
 	}
 	hostConfig := &container.HostConfig{
 		NetworkMode: container.NetworkMode(targetName),
@@ -334,7 +459,7 @@ func (cli *DebugCli) CreateContainer(attachContainer string, options execOptions
 		Mounts:      mounts,
 		SecurityOpt: options.securityOpts,
 		CapAdd:      options.capAdds,
-		AutoRemove:  true,
+		AutoRemove:  !options.keep,
 		Privileged:  options.privileged,
 	}
 
@@ -342,13 +467,30 @@ func (cli *DebugCli) CreateContainer(attachContainer string, options execOptions
 	if options.ipc {
 		hostConfig.IpcMode = container.IpcMode(targetName)
 	}
-	ctx, cancel = cli.withContent(cli.config.Timeout)
+	var (
+		id  string
+		err error
+	)
+	entrypoints := cli.entrypoints(options)
+	for i, entrypoint := range entrypoints {
+		conf.Entrypoint = entrypoint
+		id, err = cli.runContainer(conf, hostConfig)
+		if err == nil || !isExecNotFound(err) || i+1 == len(entrypoints) {
+			break
+		}
+		logrus.Debugf("entrypoint %v: %+v", entrypoint, err)
+	}
+	return id, err
+}
+
+func (cli *DebugCli) runContainer(conf *container.Config, hostConfig *container.HostConfig) (string, error) {
+	ctx, cancel := cli.withContent(cli.config.Timeout)
 	body, err := cli.client.ContainerCreate(
 		ctx,
 		conf,
 		hostConfig,
 		nil,
-		nil,
+		cli.platform,
 		"",
 	)
 	cancel()
@@ -362,12 +504,17 @@ func (cli *DebugCli) CreateContainer(attachContainer string, options execOptions
 		container.StartOptions{},
 	)
 	cancel()
-	return body.ID, errors.WithStack(err)
+	if err != nil {
+		// not started container is not auto removed
+		_ = cli.ContainerRemove(body.ID)
+		return "", errors.WithStack(err)
+	}
+	return body.ID, nil
 }
 
 // ContainerClean stop and remove container
 func (cli *DebugCli) ContainerClean(ctx context.Context, id string) error {
-	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	ctx, cancel := context.WithTimeout(ctx, cli.config.Timeout)
 	defer cancel()
 	var timeout int = 5
 	return errors.WithStack(cli.client.ContainerStop(
@@ -377,24 +524,45 @@ func (cli *DebugCli) ContainerClean(ctx context.Context, id string) error {
 	))
 }
 
+// FindSidecars find kept debug containers of the target container, newest first
+func (cli *DebugCli) FindSidecars(targetID string) ([]types.Container, error) {
+	args := sidecarFilters(targetID)
+	args.Add("label", labelKeep+"=true")
+	return cli.listContainers(args)
+}
+
+// ContainerRemove force remove container
+func (cli *DebugCli) ContainerRemove(id string) error {
+	ctx, cancel := cli.withContent(cli.config.Timeout)
+	defer cancel()
+	return errors.WithStack(cli.client.ContainerRemove(ctx, id, container.RemoveOptions{
+		Force: true,
+	}))
+}
+
 // ExecCreate exec create
 func (cli *DebugCli) ExecCreate(options execOptions, containerStr string) (types.IDResponse, error) {
 	var workDir = options.workDir
-	if workDir == "" && cli.config.MountDir != "" {
+	// exec working dir requires api 1.35, like the --work-dir annotation
+	if workDir == "" && cli.config.MountDir != "" && options.rootfs != rootfsNone &&
+		versions.GreaterThanOrEqualTo(cli.client.ClientVersion(), "1.35") {
 		workDir = path.Join(cli.config.MountDir, options.targetDir)
 	}
-	h, w := cli.out.GetTtySize()
 	opt := container.ExecOptions{
 		User:         options.user,
 		Privileged:   options.privileged,
 		DetachKeys:   options.detachKeys,
-		Tty:          true,
+		Tty:          options.tty,
 		AttachStderr: true,
 		AttachStdin:  true,
 		AttachStdout: true,
 		WorkingDir:   workDir,
+		Env:          options.env,
 		Cmd:          options.command,
-		ConsoleSize:  &[2]uint{h, w},
+	}
+	if options.tty {
+		h, w := cli.out.GetTtySize()
+		opt.ConsoleSize = &[2]uint{h, w}
 	}
 	ctx, cancel := cli.withContent(cli.config.Timeout)
 	defer cancel()
@@ -404,10 +572,27 @@ func (cli *DebugCli) ExecCreate(options execOptions, containerStr string) (types
 
 // ExecStart exec start
 func (cli *DebugCli) ExecStart(options execOptions, execID string) error {
-	h, w := cli.out.GetTtySize()
 	execConfig := container.ExecStartOptions{
-		Tty:         true,
-		ConsoleSize: &[2]uint{h, w},
+		Tty: options.tty,
+	}
+	if options.tty {
+		h, w := cli.out.GetTtySize()
+		execConfig.ConsoleSize = &[2]uint{h, w}
+	}
+
+	var recorder *tty.Recorder
+	if options.record != "" {
+		h, w := cli.out.GetTtySize()
+		r, err := tty.NewRecorder(options.record, h, w, strings.Join(options.command, " "))
+		if err != nil {
+			return err
+		}
+		recorder = r
+		defer func() {
+			if err := recorder.Close(); err != nil {
+				logrus.Debugf("%+v", err)
+			}
+		}()
 	}
 
 	ctx, cancel := cli.withContent(cli.config.Timeout)
@@ -426,13 +611,19 @@ func (cli *DebugCli) ExecStart(options execOptions, execID string) error {
 			OutputStream: cli.out,
 			ErrorStream:  cli.err,
 			Resp:         response,
-			TTY:          true,
+			TTY:          options.tty,
 			DetachKeys:   options.detachKeys,
+			Recorder:     recorder,
 		}
 		errCh <- streamer.Stream(cli.ctx)
 	}()
-	if err := tty.MonitorTtySize(cli.ctx, cli.client, cli.out, execID, true); err != nil {
-		_, _ = fmt.Fprintln(cli.err, "Error monitoring TTY size:", err)
+	if options.tty {
+		// stopped before the recorder is closed
+		monitorCtx, stopMonitor := context.WithCancel(cli.ctx)
+		defer stopMonitor()
+		if err := tty.MonitorTtySize(monitorCtx, cli.client, cli.out, execID, true, recorder); err != nil {
+			_, _ = fmt.Fprintln(cli.err, "Error monitoring TTY size:", err)
+		}
 	}
 	if err := <-errCh; err != nil {
 		logrus.Debugf("Error hijack: %s", err)
@@ -467,6 +658,15 @@ func (cli *DebugCli) WatchContainer(ctx context.Context, containerID string) err
 	}
 }
 
+// StatusError the non-zero exit status of a command run in a container
+type StatusError struct {
+	StatusCode int
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("ExitStatus %d", e.StatusCode)
+}
+
 func getExecExitStatus(ctx context.Context, apiClient client.ContainerAPIClient, execID string) error {
 	resp, err := apiClient.ContainerExecInspect(ctx, execID)
 	if err != nil {
@@ -474,16 +674,11 @@ func getExecExitStatus(ctx context.Context, apiClient client.ContainerAPIClient,
 		if !client.IsErrConnectionFailed(err) {
 			return err
 		}
-		return errors.Errorf("ExitStatus %d", -1)
+		return StatusError{StatusCode: -1}
 	}
 	status := resp.ExitCode
 	if status != 0 {
-		return errors.Errorf("ExitStatus %d", status)
+		return StatusError{StatusCode: status}
 	}
 	return nil
 }
-
-// FindContainer find container
-func (cli *DebugCli) FindContainer(name string) (string, error) {
-	return name, nil
-}
@@ -0,0 +1,56 @@
+package command
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/pkg/errors"
+	"github.com/zeromake/docker-debug/internal/config"
+)
+
+func TestForgetStaleAPIVersion(t *testing.T) {
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Api-Version", "1.30")
+		_, _ = w.Write([]byte("OK"))
+	}))
+	defer daemon.Close()
+	old := config.File
+	config.File = filepath.Join(t.TempDir(), "config.toml")
+	t.Cleanup(func() { config.File = old })
+
+	const host = "tcp://daemon:2375"
+	tests := []struct {
+		name    string
+		version string
+		err     error
+		dropped bool
+	}{
+		{"rejected version", "1.45", errors.WithStack(errdefs.InvalidParameter(errors.New("client version 1.45 is too new"))), true},
+		{"other errors", "1.45", errors.New("container not found"), false},
+		{"invalid parameter of a supported version", "1.30", errdefs.InvalidParameter(errors.New("invalid mount")), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := config.CacheAPIVersion(host, tt.version); err != nil {
+				t.Fatal(err)
+			}
+			c, err := client.NewClientWithOpts(client.WithHost("tcp://"+daemon.Listener.Addr().String()), client.WithVersion(tt.version))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var stderr bytes.Buffer
+			cli := &DebugCli{client: c, err: &stderr, cachedVersionHost: host}
+			cli.forgetStaleAPIVersion(tt.err)
+			if dropped := config.CachedAPIVersion(host) == ""; dropped != tt.dropped {
+				t.Errorf("cache dropped = %t, want %t (%s)", dropped, tt.dropped, stderr.String())
+			}
+		})
+	}
+	var nilCli *DebugCli
+	nilCli.forgetStaleAPIVersion(errdefs.InvalidParameter(errors.New("x")))
+}
@@ -0,0 +1,252 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	dockerImage "github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/strslice"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// cloneShellDir where the shell of the debug image is mounted in the copy
+const cloneShellDir = "/.docker-debug"
+
+// cloneShellScript copy the shell of the debug image with its loader and libraries,
+// so it runs in distroless or scratch copies
+const cloneShellScript = `set -e
+dir="$1"
+bin=$(readlink -f "$(command -v sh)")
+mkdir -p "$dir/lib"
+cp "$bin" "$dir/sh"
+ldd "$bin" 2>/dev/null | while read -r name arrow lib rest; do
+	case "$name" in /*) cp -L "$name" "$dir/ld" ;; esac
+	case "$lib" in /*) cp -L "$lib" "$dir/lib/" ;; esac
+done
+`
+
+// CloneContainer commit the target container and run a copy of it with the same
+// config, env, mounts and networks, the entrypoint is replaced with a shell
+func (cli *DebugCli) CloneContainer(targetID string, options execOptions) (string, string, error) {
+	ctx, cancel := cli.withContent(cli.config.Timeout)
+	info, err := cli.client.ContainerInspect(ctx, targetID)
+	cancel()
+	if err != nil {
+		return "", "", errors.WithStack(err)
+	}
+
+	ctx, cancel = cli.withContent(cli.config.Timeout)
+	commit, err := cli.client.ContainerCommit(ctx, info.ID, container.CommitOptions{
+		Comment: "docker-debug clone of " + trimContainerName(info.Name),
+		Pause:   info.State.Running,
+	})
+	cancel()
+	if err != nil {
+		return "", "", errors.WithStack(err)
+	}
+
+	shellVolume := cloneShellVolume(commit.ID)
+	entrypoint, err := cli.prepareCloneShell(shellVolume)
+	if err != nil {
+		cli.CloneClean("", commit.ID)
+		return "", "", err
+	}
+
+	conf := *info.Config
+	conf.Image = commit.ID
+	conf.Entrypoint = entrypoint
+	conf.Cmd = nil
+	conf.Tty = true
+	conf.OpenStdin = true
+	conf.StdinOnce = false
+	conf.Healthcheck = &container.HealthConfig{Test: []string{"NONE"}}
+	conf.ExposedPorts = nil
+	conf.Hostname = ""
+	conf.Labels = sidecarLabels(info, options)
+	delete(conf.Labels, labelTarget)
+	conf.Labels[labelCloneOf] = info.ID
+
+	hostConfig := *info.HostConfig
+	hostConfig.AutoRemove = false
+	hostConfig.RestartPolicy = container.RestartPolicy{Name: container.RestartPolicyDisabled}
+	hostConfig.PortBindings = nil
+	hostConfig.PublishAllPorts = false
+	hostConfig.Binds = nil
+	hostConfig.Mounts = append(cloneMounts(info.Mounts), mount.Mount{
+		Type:     mount.TypeVolume,
+		Source:   shellVolume,
+		Target:   cloneShellDir,
+		ReadOnly: true,
+	})
+
+	var extraNetworks = map[string]*network.EndpointSettings{}
+	networkingConfig := &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{},
+	}
+	if info.NetworkSettings != nil {
+		for name, endpoint := range info.NetworkSettings.Networks {
+			settings := &network.EndpointSettings{
+				Aliases:    endpoint.Aliases,
+				Links:      endpoint.Links,
+				DriverOpts: endpoint.DriverOpts,
+			}
+			if isNetworkMode(hostConfig.NetworkMode, name) {
+				networkingConfig.EndpointsConfig[name] = settings
+			} else if !hostConfig.NetworkMode.IsContainer() {
+				extraNetworks[name] = settings
+			}
+		}
+	}
+
+	ctx, cancel = cli.withContent(cli.config.Timeout)
+	body, err := cli.client.ContainerCreate(ctx, &conf, &hostConfig, networkingConfig, nil, "")
+	cancel()
+	if err != nil {
+		cli.CloneClean("", commit.ID)
+		return "", "", errors.WithStack(err)
+	}
+	for name, settings := range extraNetworks {
+		ctx, cancel = cli.withContent(cli.config.Timeout)
+		err = cli.client.NetworkConnect(ctx, name, body.ID, settings)
+		cancel()
+		if err != nil {
+			cli.CloneClean(body.ID, commit.ID)
+			return "", "", errors.WithStack(err)
+		}
+	}
+	ctx, cancel = cli.withContent(cli.config.Timeout)
+	err = cli.client.ContainerStart(ctx, body.ID, container.StartOptions{})
+	cancel()
+	if err != nil {
+		cli.CloneClean(body.ID, commit.ID)
+		return "", "", errors.Wrapf(err, "start copy of `%s` with %v", trimContainerName(info.Name), entrypoint)
+	}
+	return body.ID, commit.ID, nil
+}
+
+// CloneClean remove the copy container, its committed image and shell volume
+func (cli *DebugCli) CloneClean(cloneID, imageID string) {
+	if cloneID != "" {
+		if err := cli.ContainerRemove(cloneID); err != nil {
+			logrus.Debugf("%+v", err)
+		}
+	}
+	cli.removeImage(imageID)
+	cli.removeVolume(cloneShellVolume(imageID))
+}
+
+// cloneShellVolume name of the volume holding the shell of a copy
+func cloneShellVolume(imageID string) string {
+	id := strings.TrimPrefix(imageID, "sha256:")
+	if len(id) > shortContainerIDSize {
+		id = id[:shortContainerIDSize]
+	}
+	return "docker-debug-shell-" + id
+}
+
+// prepareCloneShell fill the volume with the shell of the debug image,
+// returns the entrypoint running it from cloneShellDir
+func (cli *DebugCli) prepareCloneShell(name string) (strslice.StrSlice, error) {
+	ctx, cancel := cli.withContent(cli.config.Timeout)
+	_, err := cli.client.VolumeCreate(ctx, volume.CreateOptions{
+		Name:   name,
+		Labels: map[string]string{labelCloneOf: ""},
+	})
+	cancel()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	id, err := cli.runContainer(&container.Config{
+		Image:      cli.config.Image,
+		Entrypoint: []string{"sh", "-c", cloneShellScript, "sh"},
+		Cmd:        []string{cloneShellDir},
+	}, &container.HostConfig{
+		AutoRemove: false,
+		Mounts: []mount.Mount{{
+			Type:   mount.TypeVolume,
+			Source: name,
+			Target: cloneShellDir,
+		}},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "copy the shell of %s", cli.config.Image)
+	}
+	defer func() {
+		_ = cli.ContainerRemove(id)
+	}()
+	ctx, cancel = cli.withContent(cli.config.Timeout)
+	defer cancel()
+	waitCh, errCh := cli.client.ContainerWait(ctx, id, container.WaitConditionNotRunning)
+	select {
+	case res := <-waitCh:
+		if res.StatusCode != 0 {
+			return nil, errors.Errorf("copy the shell of %s: exit code %d", cli.config.Image, res.StatusCode)
+		}
+	case err = <-errCh:
+		return nil, errors.WithStack(err)
+	}
+
+	shell := cloneShellDir + "/sh"
+	_, err = cli.client.ContainerStatPath(ctx, id, cloneShellDir+"/ld")
+	switch {
+	case err == nil:
+		return strslice.StrSlice{cloneShellDir + "/ld", "--library-path", cloneShellDir + "/lib", shell}, nil
+	case client.IsErrNotFound(err):
+		// static shell
+		return strslice.StrSlice{shell}, nil
+	default:
+		return nil, errors.WithStack(err)
+	}
+}
+
+func (cli *DebugCli) removeVolume(name string) {
+	ctx, cancel := cli.withContent(cli.config.Timeout)
+	defer cancel()
+	if err := cli.client.VolumeRemove(ctx, name, true); err != nil {
+		logrus.Debugf("%+v", err)
+	}
+}
+
+// isNetworkMode report whether the network is the one of mode, default is the bridge network
+func isNetworkMode(mode container.NetworkMode, name string) bool {
+	if mode.IsDefault() {
+		mode = network.NetworkBridge
+	}
+	return string(mode) == name
+}
+
+func (cli *DebugCli) removeImage(imageID string) {
+	ctx, cancel := cli.withContent(cli.config.Timeout)
+	defer cancel()
+	_, err := cli.client.ImageRemove(ctx, imageID, dockerImage.RemoveOptions{PruneChildren: true})
+	if err != nil {
+		logrus.Debugf("%+v", err)
+	}
+}
+
+// cloneMounts rebuild mounts from the inspected mount points, anonymous volumes are reused
+func cloneMounts(points []types.MountPoint) []mount.Mount {
+	var mounts []mount.Mount
+	for _, p := range points {
+		m := mount.Mount{
+			Type:     p.Type,
+			Source:   p.Source,
+			Target:   p.Destination,
+			ReadOnly: !p.RW,
+		}
+		switch p.Type {
+		case mount.TypeVolume:
+			m.Source = p.Name
+		case mount.TypeTmpfs:
+			m.Source = ""
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts
+}
@@ -1,32 +1,170 @@
 package command
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/BurntSushi/toml"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+
 	"github.com/zeromake/docker-debug/internal/config"
+	"github.com/zeromake/docker-debug/pkg/opts"
 )
 
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputTOML  = "toml"
+)
+
+// dockerConfigs the docker configs part of config.toml, used for json and toml output
+type dockerConfigs struct {
+	Default string                          `json:"config_default" toml:"config_default"`
+	Configs map[string]*config.DockerConfig `json:"config" toml:"config"`
+}
+
+// configSetters typed setters of `config set KEY VALUE`
+var configSetters = map[string]func(conf *config.Config, value string) error{
+	"image": func(conf *config.Config, value string) error {
+		if value == "" {
+			return errors.New("image can not be empty")
+		}
+		conf.Image = value
+		return nil
+	},
+	"mount_dir": func(conf *config.Config, value string) error {
+		if !path.IsAbs(value) {
+			return errors.Errorf("mount_dir must be an absolute path: `%s`", value)
+		}
+		conf.MountDir = value
+		return nil
+	},
+	"timeout": func(conf *config.Config, value string) error {
+		return setDuration(&conf.Timeout, value)
+	},
+	"read_timeout": func(conf *config.Config, value string) error {
+		return setDuration(&conf.ReadTimeout, value)
+	},
+	"gc_max_age": func(conf *config.Config, value string) error {
+		return setNonNegativeDuration(&conf.GCMaxAge, value)
+	},
+	"config_default": func(conf *config.Config, value string) error {
+		if _, ok := conf.DockerConfig[value]; !ok {
+			return errors.Errorf("not find %s config", value)
+		}
+		conf.DockerConfigDefault = value
+		return nil
+	},
+	"pull": func(conf *config.Config, value string) error {
+		switch value {
+		case "", pullAlways, pullMissing, pullNever:
+			conf.Pull = value
+			return nil
+		}
+		return errors.Errorf("invalid pull policy: `%s` (always|missing|never)", value)
+	},
+	"env_deny": func(conf *config.Config, value string) error {
+		conf.EnvDeny = splitList(value)
+		return nil
+	},
+}
+
+// dockerConfigSetters typed setters of `config set config.NAME.KEY VALUE`
+var dockerConfigSetters = map[string]func(dc *config.DockerConfig, value string) error{
+	"host": func(dc *config.DockerConfig, value string) error {
+		host, err := opts.ValidateHost(value)
+		if err != nil {
+			return err
+		}
+		if host == "" {
+			return errors.New("host can not be empty")
+		}
+		dc.Host = host
+		return nil
+	},
+	"version": func(dc *config.DockerConfig, value string) error {
+		if value != config.AutoVersion && !config.ValidAPIVersion(value) {
+			return errors.Errorf("invalid api version: `%s` (auto or format: 1.40)", value)
+		}
+		dc.Version = value
+		// negotiate again, the cached version may be the reason of the change
+		if err := config.ForgetAPIVersion(dc.Host); err != nil {
+			logrus.Debugf("%+v", err)
+		}
+		return nil
+	},
+	"tls": func(dc *config.DockerConfig, value string) error {
+		tls, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.Errorf("invalid bool: `%s`", value)
+		}
+		dc.TLS = tls
+		return nil
+	},
+	"cert_dir": func(dc *config.DockerConfig, value string) error {
+		if value != "" && !config.PathExists(value) {
+			return errors.Errorf("cert_dir `%s` not found", value)
+		}
+		dc.CertDir = value
+		return nil
+	},
+	"cert_password": func(dc *config.DockerConfig, value string) error {
+		dc.CertPassword = value
+		return nil
+	},
+}
+
 func init() {
 	cfg := &config.DockerConfig{}
 	name := ""
+	output := ""
+	var showSecrets bool
 	cmd := &cobra.Command{
 		Use:   "config",
 		Short: "docker conn config cli",
 		Args:  RequiresMinArgs(0),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			conf, err := config.LoadConfig()
+			conf, err := config.LoadFileConfig()
 			if err != nil {
 				return err
 			}
 			if cfg.Host == "" {
 				c, ok := conf.DockerConfig[name]
 				if ok {
-					fmt.Printf("config `%s`:\n%+v\n", name, c)
-					return nil
+					if !showSecrets {
+						c = c.Redacted()
+					}
+					if output == "" {
+						fmt.Printf("config `%s`:\n%+v\n", name, c)
+						return nil
+					}
+					return writeOutput(output, c)
 				}
 				return errors.Errorf("not find %s config", name)
 			}
+			if _, err = opts.ValidateHost(cfg.Host); err != nil {
+				return err
+			}
+			if cfg.Version == "" {
+				cfg.Version = config.AutoVersion
+				if old, ok := conf.DockerConfig[name]; ok && old.Version != "" {
+					cfg.Version = old.Version
+				}
+			} else if cfg.Version != config.AutoVersion && !config.ValidAPIVersion(cfg.Version) {
+				return errors.Errorf("invalid api version: `%s` (auto or format: 1.40)", cfg.Version)
+			}
+			if conf.DockerConfig == nil {
+				conf.DockerConfig = map[string]*config.DockerConfig{}
+			}
 			conf.DockerConfig[name] = cfg
 			return conf.Save()
 		},
@@ -38,5 +176,325 @@ func init() {
 	flags.StringVarP(&cfg.CertDir, "cert-dir", "c", "", "docker tls cert dir")
 	flags.StringVarP(&cfg.Host, "host", "H", "", "docker host")
 	flags.StringVarP(&cfg.CertPassword, "password", "p", "", "docker tls password")
+	flags.StringVar(&cfg.Version, "api-version", "", "docker api version to pin, auto negotiates with the daemon (default the current one or auto)")
+	flags.StringVarP(&output, "output", "o", "", "output format (json|toml)")
+	addShowSecretsFlag(cmd, &showSecrets)
+	cmd.AddCommand(newImportContextsCommand())
+	cmd.AddCommand(newConfigLsCommand())
+	cmd.AddCommand(newConfigRmCommand())
+	cmd.AddCommand(newConfigRenameCommand())
+	cmd.AddCommand(newConfigSetCommand())
+	cmd.AddCommand(newConfigViewCommand())
+	cmd.AddCommand(newConfigMigrateCommand())
 	rootCmd.AddCommand(cmd)
 }
+
+func newConfigLsCommand() *cobra.Command {
+	output := outputTable
+	var showSecrets bool
+	cmd := &cobra.Command{
+		Use:   "ls",
+		Short: "List docker configs, * marks the default one",
+		Args:  RequiresMinArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := config.LoadConfig()
+			if err != nil {
+				return err
+			}
+			if output != outputTable {
+				if !showSecrets {
+					if conf, err = conf.Redacted(); err != nil {
+						return err
+					}
+				}
+				return writeOutput(output, dockerConfigs{
+					Default: conf.DockerConfigDefault,
+					Configs: conf.DockerConfig,
+				})
+			}
+			names := make([]string, 0, len(conf.DockerConfig))
+			for name := range conf.DockerConfig {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "NAME\tHOST\tTLS\tVERSION")
+			for _, name := range names {
+				dc := conf.DockerConfig[name]
+				if name == conf.DockerConfigDefault {
+					name += " *"
+				}
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%t\t%s\n", name, dc.Host, dc.TLS, dc.Version)
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", outputTable, "output format (table|json|toml)")
+	addShowSecretsFlag(cmd, &showSecrets)
+	return cmd
+}
+
+func newConfigViewCommand() *cobra.Command {
+	var merged, showSecrets bool
+	output := ""
+	cmd := &cobra.Command{
+		Use:   "view",
+		Short: "Show the config file, or the merged config with the source of each value",
+		Long: `Show the config file, or with --merged the config merged from:
+  default < config file < project .docker-debug.toml < DOCKER_DEBUG_<FIELD> env`,
+		Args: RequiresMinArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !merged {
+				conf, err := config.LoadFileConfig()
+				if err != nil {
+					return err
+				}
+				if output == "" {
+					output = outputTOML
+				}
+				if !showSecrets {
+					if conf, err = conf.Redacted(); err != nil {
+						return err
+					}
+				}
+				return writeOutput(output, conf)
+			}
+			conf, sources, err := config.LoadMergedConfig()
+			if err != nil {
+				return err
+			}
+			if !showSecrets {
+				if conf, err = conf.Redacted(); err != nil {
+					return err
+				}
+			}
+			switch output {
+			case "":
+			case outputTOML:
+				return writeOutput(output, conf)
+			default:
+				return writeOutput(output, config.Flatten(conf, sources))
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+			for _, e := range config.Flatten(conf, sources) {
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", e.Key, e.Value, e.Source)
+			}
+			return w.Flush()
+		},
+	}
+	flags := cmd.Flags()
+	flags.BoolVar(&merged, "merged", false, "Show the merged config and where each value comes from")
+	flags.StringVarP(&output, "output", "o", "", "output format (json|toml), table by default with --merged")
+	addShowSecretsFlag(cmd, &showSecrets)
+	return cmd
+}
+
+// addShowSecretsFlag cert and registry passwords are redacted in output unless --show-secrets
+func addShowSecretsFlag(cmd *cobra.Command, showSecrets *bool) {
+	cmd.Flags().BoolVar(showSecrets, "show-secrets", false, "Show cert and registry passwords instead of "+config.Redacted)
+}
+
+func newConfigMigrateCommand() *cobra.Command {
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate the config file to this version, the old file is kept as a backup",
+		Args:  RequiresMinArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, md, err := config.ReadFile()
+			if err != nil {
+				return err
+			}
+			for _, key := range md.Undecoded() {
+				fmt.Printf("unknown key `%s` will be dropped\n", key)
+			}
+			migrated, steps, err := config.PlanMigration(conf)
+			if err != nil {
+				return err
+			}
+			if migrated == nil {
+				fmt.Println("config is up to date")
+				return nil
+			}
+			for _, step := range steps {
+				fmt.Printf("# %s\n%s\n", step.Name, strings.Join(step.Diff, "\n"))
+			}
+			if err = migrated.Validate(); err != nil {
+				return err
+			}
+			if dryRun {
+				return nil
+			}
+			return config.MigrationConfig(conf)
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what each migration would change without writing the file")
+	return cmd
+}
+
+func newConfigRmCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rm NAME [NAME...]",
+		Short: "Remove docker configs",
+		Args:  RequiresMinArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := config.LoadFileConfig()
+			if err != nil {
+				return err
+			}
+			for _, name := range args {
+				if _, ok := conf.DockerConfig[name]; !ok {
+					return errors.Errorf("not find %s config", name)
+				}
+				if name == conf.DockerConfigDefault {
+					return errors.Errorf("%s is the default config, switch with `docker-debug use` first", name)
+				}
+				delete(conf.DockerConfig, name)
+			}
+			return conf.Save()
+		},
+	}
+}
+
+func newConfigRenameCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rename OLD NEW",
+		Short: "Rename a docker config",
+		Args:  RequiresMinArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := config.LoadFileConfig()
+			if err != nil {
+				return err
+			}
+			oldName, newName := args[0], args[1]
+			dc, ok := conf.DockerConfig[oldName]
+			if !ok {
+				return errors.Errorf("not find %s config", oldName)
+			}
+			if _, ok = conf.DockerConfig[newName]; ok {
+				return errors.Errorf("config %s already exists", newName)
+			}
+			delete(conf.DockerConfig, oldName)
+			conf.DockerConfig[newName] = dc
+			if conf.DockerConfigDefault == oldName {
+				conf.DockerConfigDefault = newName
+			}
+			return conf.Save()
+		},
+	}
+}
+
+func newConfigSetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set KEY VALUE",
+		Short: "Set a config value",
+		Long: `Set a config value, values are validated by type.
+Keys: ` + strings.Join(configSetKeys(), ", ") + `
+      config.NAME.{` + strings.Join(sortedKeys(dockerConfigSetters), ",") + `}`,
+		Example: `  docker-debug config set timeout 30s
+  docker-debug config set config.prod.host ssh://deploy@prod.example.com`,
+		Args: RequiresMinArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := config.LoadFileConfig()
+			if err != nil {
+				return err
+			}
+			if err = setConfig(conf, args[0], args[1]); err != nil {
+				return err
+			}
+			return conf.Save()
+		},
+	}
+}
+
+// setConfig set KEY, config.NAME.KEY sets the docker config NAME which is created when missing
+func setConfig(conf *config.Config, key, value string) error {
+	if setter, ok := configSetters[key]; ok {
+		return setter(conf, value)
+	}
+	if !strings.HasPrefix(key, "config.") {
+		return errors.Errorf("unknown config key: `%s` (keys: %s)", key, strings.Join(configSetKeys(), ", "))
+	}
+	i := strings.LastIndex(key, ".")
+	name, field := key[len("config."):i], key[i+1:]
+	setter, ok := dockerConfigSetters[field]
+	if name == "" || !ok {
+		return errors.Errorf(
+			"unknown config key: `%s` (format: config.NAME.{%s})",
+			key,
+			strings.Join(sortedKeys(dockerConfigSetters), ","),
+		)
+	}
+	if conf.DockerConfig == nil {
+		conf.DockerConfig = map[string]*config.DockerConfig{}
+	}
+	dc, ok := conf.DockerConfig[name]
+	if !ok {
+		dc = &config.DockerConfig{Version: config.AutoVersion}
+	}
+	if err := setter(dc, value); err != nil {
+		return err
+	}
+	if dc.Host == "" {
+		return errors.Errorf("new config %s requires config.%s.host first", name, name)
+	}
+	conf.DockerConfig[name] = dc
+	return nil
+}
+
+func setDuration(dst *time.Duration, value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return errors.Errorf("invalid duration: `%s` (e.g. 10s, 1m30s)", value)
+	}
+	*dst = d
+	return nil
+}
+
+// setNonNegativeDuration like setDuration, 0 is accepted to disable the setting
+func setNonNegativeDuration(dst *time.Duration, value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return errors.Errorf("invalid duration: `%s` (e.g. 0, 10s, 1m30s)", value)
+	}
+	*dst = d
+	return nil
+}
+
+// splitList split a comma separated list, empty values are dropped
+func splitList(value string) []string {
+	var list []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func configSetKeys() []string {
+	return sortedKeys(configSetters)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeOutput write v to stdout as json or toml
+func writeOutput(output string, v interface{}) error {
+	switch output {
+	case outputJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return errors.WithStack(encoder.Encode(v))
+	case outputTOML:
+		return errors.WithStack(toml.NewEncoder(os.Stdout).Encode(v))
+	}
+	return errors.Errorf("unknown output: `%s`", output)
+}
@@ -0,0 +1,43 @@
+package command
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zeromake/docker-debug/internal/config"
+)
+
+func TestConfigSetDurations(t *testing.T) {
+	tests := []struct {
+		key, value string
+		get        func(c *config.Config) time.Duration
+		want       time.Duration
+		err        bool
+	}{
+		{"timeout", "30s", func(c *config.Config) time.Duration { return c.Timeout }, time.Second * 30, false},
+		{"timeout", "0", nil, 0, true},
+		{"timeout", "-1s", nil, 0, true},
+		{"gc_max_age", "0", func(c *config.Config) time.Duration { return c.GCMaxAge }, 0, false},
+		{"gc_max_age", "48h", func(c *config.Config) time.Duration { return c.GCMaxAge }, time.Hour * 48, false},
+		{"gc_max_age", "-1h", nil, 0, true},
+		{"gc_max_age", "soon", nil, 0, true},
+	}
+	for _, tt := range tests {
+		conf := &config.Config{Timeout: time.Second, GCMaxAge: time.Hour}
+		err := configSetters[tt.key](conf, tt.value)
+		if tt.err {
+			if err == nil || !strings.Contains(err.Error(), "invalid duration") {
+				t.Errorf("set %s %s error = %v, want invalid duration", tt.key, tt.value, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("set %s %s error = %v", tt.key, tt.value, err)
+			continue
+		}
+		if got := tt.get(conf); got != tt.want {
+			t.Errorf("set %s %s = %s, want %s", tt.key, tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/zeromake/docker-debug/internal/config"
+	"github.com/zeromake/docker-debug/pkg/credentials"
+	"github.com/zeromake/docker-debug/pkg/dockercontext"
+)
+
+// newImportContextsCommand `config import-contexts`
+func newImportContextsCommand() *cobra.Command {
+	var overwrite bool
+	cmd := &cobra.Command{
+		Use:   "import-contexts [NAME...]",
+		Short: "Import docker cli contexts as docker configs, all contexts by default",
+		Long: `Import docker cli contexts (~/.docker/contexts) as docker configs named by the context.
+The current docker context (DOCKER_CONTEXT or currentContext) becomes the default config.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImportContexts(args, overwrite)
+		},
+	}
+	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "Replace existing docker configs with the same name")
+	return cmd
+}
+
+func runImportContexts(names []string, overwrite bool) error {
+	conf, err := config.LoadFileConfig()
+	if err != nil {
+		return err
+	}
+	dir := credentials.Dir()
+	contexts, err := dockercontext.List(dir)
+	if err != nil {
+		return err
+	}
+	if len(names) > 0 {
+		contexts = contexts[:0]
+		for _, name := range names {
+			c, err := dockercontext.Get(dir, name)
+			if err != nil {
+				return err
+			}
+			contexts = append(contexts, c)
+		}
+	}
+	current, err := dockercontext.Current(dir)
+	if err != nil {
+		return err
+	}
+	if conf.DockerConfig == nil {
+		conf.DockerConfig = map[string]*config.DockerConfig{}
+	}
+
+	imported := map[string]bool{}
+	for _, c := range contexts {
+		if _, ok := conf.DockerConfig[c.Name]; ok && !overwrite {
+			fmt.Printf("skip `%s`: docker config exists, use --overwrite to replace it\n", c.Name)
+			continue
+		}
+		conf.DockerConfig[c.Name] = contextDockerConfig(c)
+		imported[c.Name] = true
+		fmt.Printf("import `%s`: %s\n", c.Name, c.Host)
+	}
+	if imported[current] && conf.DockerConfigDefault != current {
+		conf.DockerConfigDefault = current
+		fmt.Printf("default config: `%s` (current docker context)\n", current)
+	}
+	return conf.Save()
+}
+
+// contextDockerConfig convert a docker cli context, its tls material is used in place
+func contextDockerConfig(c dockercontext.Context) *config.DockerConfig {
+	dc := &config.DockerConfig{
+		Host:    c.Host,
+		Version: config.AutoVersion,
+	}
+	if c.TLSDir != "" {
+		dc.TLS = true
+		dc.CertDir = c.TLSDir
+	}
+	if c.SkipTLSVerify {
+		logrus.Warnf("docker context `%s` skips tls verify, it is not supported and the server certificate is verified", c.Name)
+	}
+	return dc
+}
+
+// resolveDockerContext returns the docker config of a docker cli context, default is DOCKER_HOST
+func resolveDockerContext(name string) (*config.DockerConfig, error) {
+	if name == dockercontext.DefaultName {
+		return config.EnvDockerConfig()
+	}
+	c, err := dockercontext.Get(credentials.Dir(), name)
+	if err != nil {
+		return nil, err
+	}
+	if c.Host == "" {
+		return nil, errors.Errorf("docker context `%s` has no host", name)
+	}
+	return contextDockerConfig(c), nil
+}
@@ -0,0 +1,373 @@
+package command
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	options := newExecOptions()
+	cmd := &cobra.Command{
+		Use: `cp [OPTIONS] CONTAINER:SRC_PATH DEST_PATH|-
+  docker-debug cp [OPTIONS] SRC_PATH|- CONTAINER:DEST_PATH`,
+		Short: "Copy files between the local filesystem and a target container",
+		Long: `Copy files between the local filesystem and a target container.
+Paths only visible in the target mount namespace (like tmpfs) are copied
+through a debug container which binds /proc/<pid>/root of the target at
+mount_dir (the pid rootfs strategy).
+Use '-' to write a tar archive to stdout or read it from stdin.`,
+		Args: RequiresMinArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCp(options, args[0], args[1])
+		},
+	}
+	flags := cmd.Flags()
+	flags.SetInterspersed(false)
+	flags.StringVarP(&options.image, "image", "i", "", "use this image")
+	addClientFlags(flags, &options)
+	addImageFlags(flags, &options)
+	rootCmd.AddCommand(cmd)
+}
+
+// splitCpArg split CONTAINER:PATH, local paths returns an empty container
+func splitCpArg(arg string) (string, string) {
+	if arg == "-" || filepath.IsAbs(arg) || strings.HasPrefix(arg, ".") {
+		return "", arg
+	}
+	offset := 0
+	if strings.HasPrefix(arg, composeTargetPrefix) {
+		offset = len(composeTargetPrefix)
+	}
+	i := strings.Index(arg[offset:], ":")
+	if i == -1 {
+		return "", arg
+	}
+	return arg[:offset+i], arg[offset+i+1:]
+}
+
+func runCp(options execOptions, src, dst string) error {
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	srcContainer, srcPath := splitCpArg(src)
+	dstContainer, dstPath := splitCpArg(dst)
+	if (srcContainer == "") == (dstContainer == "") {
+		return errors.New("must specify exactly one container path (CONTAINER:PATH)")
+	}
+
+	cli, err := buildCli(ctx, options)
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	if srcContainer != "" {
+		options.container, err = cli.FindContainer(srcContainer)
+		if err != nil {
+			return err
+		}
+		return cli.CopyFrom(options, srcPath, dstPath)
+	}
+	options.container, err = cli.FindContainer(dstContainer)
+	if err != nil {
+		return err
+	}
+	return cli.CopyTo(options, srcPath, dstPath)
+}
+
+// CopyFrom copy srcPath of the target container to local dstPath
+func (cli *DebugCli) CopyFrom(options execOptions, srcPath, dstPath string) error {
+	ctx, cancel := context.WithCancel(cli.ctx)
+	defer cancel()
+	reader, _, err := cli.client.CopyFromContainer(ctx, options.container, srcPath)
+	if client.IsErrNotFound(err) {
+		containerID, rootPath, cleanup, sidecarErr := cli.rootfsSidecar(options, srcPath)
+		if sidecarErr != nil {
+			return errors.Wrap(err, sidecarErr.Error())
+		}
+		defer cleanup()
+		reader, _, err = cli.client.CopyFromContainer(ctx, containerID, rootPath)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer reader.Close()
+	if dstPath == "-" {
+		_, err = io.Copy(cli.out, reader)
+		return errors.WithStack(err)
+	}
+	return extractTar(reader, dstPath)
+}
+
+// CopyTo copy local srcPath to dstPath of the target container
+func (cli *DebugCli) CopyTo(options execOptions, srcPath, dstPath string) error {
+	ctx, cancel := context.WithCancel(cli.ctx)
+	defer cancel()
+
+	containerID := options.container
+	dstDir, name, err := cli.resolveCopyDst(ctx, containerID, dstPath, srcPath)
+	if client.IsErrNotFound(err) {
+		var (
+			rootPath string
+			cleanup  func()
+		)
+		containerID, rootPath, cleanup, err = cli.rootfsSidecar(options, dstPath)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		dstDir, name, err = cli.resolveCopyDst(ctx, containerID, rootPath, srcPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	var content io.Reader
+	if srcPath == "-" {
+		content = cli.in
+	} else {
+		if _, err = os.Lstat(srcPath); err != nil {
+			return errors.WithStack(err)
+		}
+		reader, writer := io.Pipe()
+		go func() {
+			_ = writer.CloseWithError(writeTar(writer, srcPath, name))
+		}()
+		defer reader.Close()
+		content = reader
+	}
+	return errors.WithStack(cli.client.CopyToContainer(ctx, containerID, dstDir, content, container.CopyToContainerOptions{}))
+}
+
+// resolveCopyDst returns the existing container directory to extract in and the archive root name
+func (cli *DebugCli) resolveCopyDst(ctx context.Context, containerID, dstPath, srcPath string) (string, string, error) {
+	stat, err := cli.client.ContainerStatPath(ctx, containerID, dstPath)
+	if err == nil && stat.Mode.IsDir() {
+		return dstPath, filepath.Base(srcPath), nil
+	}
+	if err != nil && !client.IsErrNotFound(err) {
+		return "", "", errors.WithStack(err)
+	}
+	dstDir := path.Dir(dstPath)
+	if _, err = cli.client.ContainerStatPath(ctx, containerID, dstDir); err != nil {
+		return "", "", err
+	}
+	return dstDir, path.Base(dstPath), nil
+}
+
+// rootfsSidecar returns a debug container with the target rootfs at MountDir and
+// the container path under it, a temporary debug container is created when none is running
+func (cli *DebugCli) rootfsSidecar(options execOptions, containerPath string) (string, string, func(), error) {
+	rootPath := path.Join(cli.config.MountDir, path.Clean("/"+containerPath))
+	if cli.config.MountDir == "" {
+		return "", "", nil, errors.New("mount_dir is not set")
+	}
+	sidecars, err := cli.listContainers(sidecarFilters(options.container))
+	if err != nil {
+		return "", "", nil, err
+	}
+	// merged sidecars recreate tmpfs mounts empty, only /proc/<pid>/root has the target mounts
+	for _, c := range sidecars {
+		if c.State == "running" && c.Labels[labelRootfs] == rootfsPid {
+			return c.ID, rootPath, func() {}, nil
+		}
+	}
+	if err = cli.EnsureImage(options); err != nil {
+		return "", "", nil, err
+	}
+	options.keep = false
+	options.rootfs = rootfsPid
+	containerID, strategy, err := cli.CreateContainer(options.container, options)
+	if err != nil {
+		return "", "", nil, err
+	}
+	cleanup := func() {
+		if err := cli.ContainerClean(cli.ctx, containerID); err != nil {
+			logrus.Debugf("%+v", err)
+		}
+	}
+	if strategy != rootfsPid {
+		cleanup()
+		return "", "", nil, errors.New("target rootfs is not reachable through a debug container")
+	}
+	return containerID, rootPath, cleanup, nil
+}
+
+// extractTar extract the archive to dstPath, rebased on its first entry,
+// a root entry of `.` or `/` extracts the content of the directory
+func extractTar(r io.Reader, dstPath string) error {
+	tr := tar.NewReader(r)
+	var rootName, destRoot string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		name := archiveName(hdr.Name)
+		if destRoot == "" {
+			rootName, destRoot = name, dstPath
+			if info, err := os.Stat(dstPath); err == nil && info.IsDir() && rootName != "." {
+				destRoot = filepath.Join(dstPath, path.Base(rootName))
+			}
+		}
+		target, err := archiveTarget(rootName, destRoot, hdr.Name)
+		if err != nil {
+			return err
+		}
+		mode := hdr.FileInfo().Mode().Perm()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err = os.MkdirAll(target, mode|0700); err != nil {
+				return errors.WithStack(err)
+			}
+		case tar.TypeReg:
+			// a symlink left by an earlier entry must not be followed,
+			// only dstPath itself is resolved
+			if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 && target != dstPath {
+				if err = os.Remove(target); err != nil {
+					return errors.WithStack(err)
+				}
+			}
+			if err = writeFile(target, tr, mode); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			_ = os.Remove(target)
+			if err = os.Symlink(hdr.Linkname, target); err != nil {
+				logrus.Warnf("skip symlink %s: %s", target, err)
+			}
+		case tar.TypeLink:
+			source, err := archiveTarget(rootName, destRoot, hdr.Linkname)
+			if err != nil {
+				return err
+			}
+			_ = os.Remove(target)
+			if err = os.Link(source, target); err != nil {
+				return errors.WithStack(err)
+			}
+		default:
+			logrus.Warnf("skip archive entry %s: unsupported type %c", hdr.Name, hdr.Typeflag)
+		}
+	}
+}
+
+// archiveName clean an archive entry name, relative to the archive root
+func archiveName(name string) string {
+	name = path.Clean("/" + name)[1:]
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// archiveTarget returns the local path of an archive entry under destRoot,
+// an error when the entry is outside of rootName or escapes through a symlink
+func archiveTarget(rootName, destRoot, entry string) (string, error) {
+	if strings.HasPrefix(path.Clean(entry), "../") || path.Clean(entry) == ".." {
+		return "", errors.Errorf("invalid archive entry: `%s`", entry)
+	}
+	name := archiveName(entry)
+	var rel string
+	switch {
+	case name == rootName:
+		rel = "."
+	case rootName == ".":
+		rel = name
+	case strings.HasPrefix(name, rootName+"/"):
+		rel = name[len(rootName)+1:]
+	default:
+		return "", errors.Errorf("archive entry `%s` is outside of `%s`", entry, rootName)
+	}
+	target := filepath.Join(destRoot, filepath.FromSlash(rel))
+	if !insideDir(destRoot, target) {
+		return "", errors.Errorf("archive entry `%s` escapes through a symlink", entry)
+	}
+	return target, nil
+}
+
+// insideDir reports whether the parent of target resolves inside root
+func insideDir(root, target string) bool {
+	if target == root {
+		return true
+	}
+	realRoot, err := filepath.EvalSymlinks(filepath.Dir(root))
+	if err != nil {
+		return false
+	}
+	realRoot = filepath.Join(realRoot, filepath.Base(root))
+	parent, err := filepath.EvalSymlinks(filepath.Dir(target))
+	if err != nil {
+		return false
+	}
+	return parent == realRoot || strings.HasPrefix(parent, realRoot+string(filepath.Separator))
+}
+
+func writeFile(target string, r io.Reader, mode os.FileMode) error {
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer file.Close()
+	_, err = io.Copy(file, r)
+	return errors.WithStack(err)
+}
+
+// writeTar write srcPath to the archive with rootName as its root entry
+func writeTar(w io.Writer, srcPath, rootName string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(srcPath, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcPath, file)
+		if err != nil {
+			return err
+		}
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(rootName, filepath.ToSlash(rel))
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err = tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(tw.Close())
+}
@@ -0,0 +1,216 @@
+package command
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type tarEntry struct {
+	name     string
+	typeflag byte
+	body     string
+	linkname string
+}
+
+func buildTar(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:     e.name,
+			Typeflag: e.typeflag,
+			Linkname: e.linkname,
+			Mode:     0644,
+			Size:     int64(len(e.body)),
+		}
+		if e.typeflag == tar.TypeDir {
+			hdr.Mode = 0755
+		}
+		if e.typeflag != tar.TypeReg {
+			hdr.Size = 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			if _, err := tw.Write([]byte(e.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestSplitCpArg(t *testing.T) {
+	tests := []struct {
+		arg, container, path string
+	}{
+		{"web:/etc/hosts", "web", "/etc/hosts"},
+		{"web:", "web", ""},
+		{"/tmp/a:b", "", "/tmp/a:b"},
+		{"./web:/etc", "", "./web:/etc"},
+		{"-", "", "-"},
+		{"local", "", "local"},
+		{"compose:shop/web:/tmp", "compose:shop/web", "/tmp"},
+	}
+	for _, tt := range tests {
+		container, p := splitCpArg(tt.arg)
+		if container != tt.container || p != tt.path {
+			t.Errorf("splitCpArg(%q) = %q, %q, want %q, %q", tt.arg, container, p, tt.container, tt.path)
+		}
+	}
+}
+
+func TestExtractTar(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		// dst is created as a directory when true
+		dstDir bool
+		// files maps paths relative to dst to their content, "->x" for a symlink to x
+		files map[string]string
+		err   string
+	}{
+		{
+			name: "file to new path",
+			entries: []tarEntry{
+				{name: "hosts", typeflag: tar.TypeReg, body: "127.0.0.1"},
+			},
+			files: map[string]string{"": "127.0.0.1"},
+		},
+		{
+			name: "directory into existing directory",
+			entries: []tarEntry{
+				{name: "etc/", typeflag: tar.TypeDir},
+				{name: "etc/hosts", typeflag: tar.TypeReg, body: "h"},
+				{name: "etc/link", typeflag: tar.TypeSymlink, linkname: "hosts"},
+				{name: "etc/hard", typeflag: tar.TypeLink, linkname: "etc/hosts"},
+			},
+			dstDir: true,
+			files:  map[string]string{"etc/hosts": "h", "etc/link": "->hosts", "etc/hard": "h"},
+		},
+		{
+			name: "dot root extracts the content",
+			entries: []tarEntry{
+				{name: "./", typeflag: tar.TypeDir},
+				{name: "./a", typeflag: tar.TypeReg, body: "a"},
+				{name: "b/", typeflag: tar.TypeDir},
+				{name: "b/c", typeflag: tar.TypeReg, body: "c"},
+			},
+			dstDir: true,
+			files:  map[string]string{"a": "a", "b/c": "c"},
+		},
+		{
+			name: "slash root extracts the content",
+			entries: []tarEntry{
+				{name: "/", typeflag: tar.TypeDir},
+				{name: "/etc/", typeflag: tar.TypeDir},
+				{name: "/etc/hosts", typeflag: tar.TypeReg, body: "h"},
+			},
+			dstDir: true,
+			files:  map[string]string{"etc/hosts": "h"},
+		},
+		{
+			name: "parent entry",
+			entries: []tarEntry{
+				{name: "etc/", typeflag: tar.TypeDir},
+				{name: "etc/../../evil", typeflag: tar.TypeReg, body: "x"},
+			},
+			dstDir: true,
+			err:    "invalid archive entry",
+		},
+		{
+			name: "dot root parent entry",
+			entries: []tarEntry{
+				{name: "./", typeflag: tar.TypeDir},
+				{name: "../evil", typeflag: tar.TypeReg, body: "x"},
+			},
+			dstDir: true,
+			err:    "invalid archive entry",
+		},
+		{
+			name: "absolute entry outside of the root",
+			entries: []tarEntry{
+				{name: "etc/", typeflag: tar.TypeDir},
+				{name: "/tmp/evil", typeflag: tar.TypeReg, body: "x"},
+			},
+			dstDir: true,
+			err:    "is outside of `etc`",
+		},
+		{
+			name: "symlink escape",
+			entries: []tarEntry{
+				{name: "etc/", typeflag: tar.TypeDir},
+				{name: "etc/link", typeflag: tar.TypeSymlink, linkname: "/"},
+				{name: "etc/link/evil", typeflag: tar.TypeReg, body: "x"},
+			},
+			dstDir: true,
+			err:    "escapes through a symlink",
+		},
+		{
+			name: "file entry replaces a symlink",
+			entries: []tarEntry{
+				{name: "etc/", typeflag: tar.TypeDir},
+				{name: "etc/evil", typeflag: tar.TypeSymlink, linkname: "../../outside"},
+				{name: "etc/evil", typeflag: tar.TypeReg, body: "x"},
+			},
+			dstDir: true,
+			files:  map[string]string{"etc/evil": "x", "../outside": "keep"},
+		},
+		{
+			name: "hardlink outside of the root",
+			entries: []tarEntry{
+				{name: "etc/", typeflag: tar.TypeDir},
+				{name: "etc/hard", typeflag: tar.TypeLink, linkname: "../outside"},
+			},
+			dstDir: true,
+			err:    "invalid archive entry",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "outside"), []byte("keep"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			dst := filepath.Join(dir, "dst")
+			if tt.dstDir {
+				if err := os.Mkdir(dst, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := extractTar(buildTar(t, tt.entries), dst)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("extractTar() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("extractTar() error = %+v", err)
+			}
+			for rel, want := range tt.files {
+				file := filepath.Join(dst, filepath.FromSlash(rel))
+				if strings.HasPrefix(want, "->") {
+					link, err := os.Readlink(file)
+					if err != nil || link != want[2:] {
+						t.Errorf("%s links to %q (%v), want %q", rel, link, err, want[2:])
+					}
+					continue
+				}
+				data, err := os.ReadFile(file)
+				if err != nil || string(data) != want {
+					t.Errorf("%s = %q (%v), want %q", rel, data, err, want)
+				}
+			}
+		})
+	}
+}
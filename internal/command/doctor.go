@@ -0,0 +1,405 @@
+package command
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/api/types/versions"
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/zeromake/docker-debug/internal/config"
+)
+
+const (
+	checkPass = "PASS"
+	checkWarn = "WARN"
+	checkFail = "FAIL"
+
+	// certExpiryWarning warn about tls certificates expiring sooner
+	certExpiryWarning = time.Hour * 24 * 30
+	// minAPIVersion oldest docker api version docker-debug works with
+	minAPIVersion = "1.25"
+)
+
+// checkResult a line of the doctor report
+type checkResult struct {
+	Status string
+	Name   string
+	Detail string
+	Hint   string
+}
+
+type doctorReport struct {
+	results []checkResult
+}
+
+func (r *doctorReport) add(status, name, detail, hint string) {
+	r.results = append(r.results, checkResult{Status: status, Name: name, Detail: detail, Hint: hint})
+	res := r.results[len(r.results)-1]
+	fmt.Printf("[%s] %-12s %s\n", res.Status, res.Name, res.Detail)
+	if res.Hint != "" && res.Status != checkPass {
+		fmt.Printf("       %-12s hint: %s\n", "", res.Hint)
+	}
+}
+
+func (r *doctorReport) pass(name, format string, args ...interface{}) {
+	r.add(checkPass, name, fmt.Sprintf(format, args...), "")
+}
+
+func (r *doctorReport) warn(name, detail, hint string) {
+	r.add(checkWarn, name, detail, hint)
+}
+
+func (r *doctorReport) fail(name, detail, hint string) {
+	r.add(checkFail, name, detail, hint)
+}
+
+// err returns an error when any check failed, so the exit code reflects the report
+func (r *doctorReport) err() error {
+	var failed, warned int
+	for _, res := range r.results {
+		switch res.Status {
+		case checkFail:
+			failed++
+		case checkWarn:
+			warned++
+		}
+	}
+	fmt.Printf("\n%d checks, %d failed, %d warnings\n", len(r.results), failed, warned)
+	if failed > 0 {
+		return errors.Errorf("%d checks failed", failed)
+	}
+	return nil
+}
+
+func init() {
+	options := newExecOptions()
+	var forgetAPIVersion bool
+	cmd := &cobra.Command{
+		Use:   "doctor [OPTIONS] [TARGET]",
+		Short: "Diagnose the config, the docker host, the debug image and the target container",
+		Long: `Diagnose the config, the docker host, the debug image and, given a target,
+whether the target container can be debugged. Each check passes, warns or
+fails with a remediation hint, the exit code is non-zero when a check fails.`,
+		Args: RequiresMinArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				options.container = args[0]
+			}
+			return runDoctor(options, forgetAPIVersion)
+		},
+	}
+	flags := cmd.Flags()
+	flags.SetInterspersed(false)
+	flags.StringVarP(&options.image, "image", "i", "", "use this image")
+	addClientFlags(flags, &options)
+	flags.BoolVar(&forgetAPIVersion, "forget-api-version", false, "Drop the api version cached for the host and negotiate it again")
+	rootCmd.AddCommand(cmd)
+}
+
+func runDoctor(options execOptions, forgetAPIVersion bool) error {
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	logrus.SetLevel(logrus.ErrorLevel)
+	report := &doctorReport{}
+
+	conf, sources, err := config.LoadMergedConfig()
+	if err != nil {
+		report.fail("config", err.Error(), "fix the file or inspect it with `docker-debug config migrate --dry-run`")
+		return report.err()
+	}
+	report.pass("config", "%s", config.File)
+	if options.image != "" {
+		conf.Image = options.image
+	}
+	if err = conf.Validate(); err != nil {
+		report.warn("config", strings.ReplaceAll(err.Error(), "\n", "\n                     "), "fix the values with `docker-debug config set KEY VALUE`")
+	}
+	for _, source := range sources {
+		if strings.HasSuffix(source, config.ProjectFileName) {
+			report.pass("config", "project file %s", source)
+			break
+		}
+	}
+
+	dockerConfig, err := selectDockerConfig(conf, options)
+	if err != nil {
+		report.fail("docker", err.Error(), "list the docker configs with `docker-debug config ls`")
+		return report.err()
+	}
+	if forgetAPIVersion {
+		if err = config.ForgetAPIVersion(dockerConfig.Host); err != nil {
+			report.warn("api", fmt.Sprintf("drop the cached api version: %s", err), "")
+		} else {
+			report.pass("api", "cached api version of %s dropped", dockerConfig.Host)
+		}
+	}
+	cli, err := NewDebugCli(ctx, WithConfig(conf), WithClientConfig(dockerConfig))
+	if err != nil {
+		report.fail("docker", err.Error(), "check the host format (unix://, tcp://, ssh://user@host) and the tls files")
+		return report.err()
+	}
+	defer cli.Close()
+
+	checkTLS(report, dockerConfig)
+	ping, err := cli.Ping()
+	if err != nil {
+		hint := "check that the daemon is running and the host is right (`docker-debug config ls`)"
+		if strings.HasPrefix(dockerConfig.Host, "ssh://") {
+			hint = "check `ssh " + strings.TrimPrefix(dockerConfig.Host, "ssh://") + " docker version` works without a password prompt"
+		}
+		report.fail("host", fmt.Sprintf("%s is unreachable: %s", dockerConfig.Host, err), hint)
+		return report.err()
+	}
+	report.pass("host", "%s reachable (%s)", dockerConfig.Host, ping.OSType)
+	checkAPIVersion(report, cli, dockerConfig, ping)
+
+	ctxInfo, cancelInfo := cli.withContent(cli.config.Timeout)
+	info, err := cli.client.Info(ctxInfo)
+	cancelInfo()
+	if err != nil {
+		report.warn("host", fmt.Sprintf("docker info: %s", err), "")
+	}
+	checkImage(report, cli)
+
+	if options.container != "" {
+		checkTarget(report, cli, info, options.container)
+	}
+	return report.err()
+}
+
+// checkTLS check the tls files in CertDir and the certificate expiry
+func checkTLS(report *doctorReport, dc *config.DockerConfig) {
+	if !dc.TLS {
+		if strings.HasPrefix(dc.Host, "tcp://") {
+			report.warn("tls", "tcp host without tls, the daemon is open to anyone reaching it", "use tls (`config set config.NAME.tls true`) or ssh://user@host")
+		}
+		return
+	}
+	for _, name := range []string{caKey, certKey, keyKey} {
+		if !config.PathExists(filepath.Join(dc.CertDir, name)) {
+			report.fail("tls", fmt.Sprintf("%s not found in %s", name, dc.CertDir), "set cert_dir to a directory holding ca.pem, cert.pem and key.pem")
+			return
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dc.CertDir, certKey))
+	if err != nil {
+		report.fail("tls", err.Error(), "")
+		return
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		report.fail("tls", fmt.Sprintf("%s is not a pem certificate", certKey), "regenerate the client certificate")
+		return
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		report.fail("tls", fmt.Sprintf("parse %s: %s", certKey, err), "regenerate the client certificate")
+		return
+	}
+	now := time.Now()
+	switch {
+	case now.After(cert.NotAfter):
+		report.fail("tls", fmt.Sprintf("client certificate expired on %s", cert.NotAfter.Format(time.RFC3339)), "renew the client certificate in "+dc.CertDir)
+	case now.Before(cert.NotBefore):
+		report.fail("tls", fmt.Sprintf("client certificate is valid from %s", cert.NotBefore.Format(time.RFC3339)), "check the local clock")
+	case cert.NotAfter.Sub(now) < certExpiryWarning:
+		report.warn("tls", fmt.Sprintf("client certificate expires on %s", cert.NotAfter.Format(time.RFC3339)), "renew the client certificate in "+dc.CertDir)
+	default:
+		report.pass("tls", "client certificate valid until %s", cert.NotAfter.Format("2006-01-02"))
+	}
+}
+
+// checkAPIVersion compare the client api version, pinned, cached or negotiated, with the daemon one
+func checkAPIVersion(report *doctorReport, cli *DebugCli, dc *config.DockerConfig, ping types.Ping) {
+	clientVersion := cli.client.ClientVersion()
+	daemonVersion := ping.APIVersion
+	mode := "negotiated"
+	hint := ""
+	switch {
+	case !config.IsAutoVersion(dc.Version):
+		mode = "pinned"
+		hint = "negotiate it with `docker-debug config set config.NAME.version auto`"
+	case cli.cachedVersionHost == dc.Host:
+		mode = "cached"
+		hint = "negotiate it again with `docker-debug doctor --forget-api-version`"
+	}
+	switch {
+	case daemonVersion != "" && versions.LessThan(daemonVersion, minAPIVersion):
+		report.fail("api", fmt.Sprintf("daemon api %s is older than %s", daemonVersion, minAPIVersion), "upgrade docker on the host")
+	case daemonVersion != "" && versions.GreaterThan(clientVersion, daemonVersion):
+		report.fail("api", fmt.Sprintf("%s api %s is newer than the daemon api %s", mode, clientVersion, daemonVersion), hint)
+	case mode != "negotiated" && daemonVersion != "" && versions.LessThan(clientVersion, daemonVersion) &&
+		versions.LessThan(clientVersion, api.DefaultVersion):
+		report.warn("api", fmt.Sprintf("%s api %s is older than the daemon api %s, newer features are disabled", mode, clientVersion, daemonVersion), hint)
+	default:
+		report.pass("api", "%s api %s (daemon %s)", mode, clientVersion, daemonVersion)
+	}
+}
+
+// checkImage check the debug image is present, or can be pulled with the configured credentials
+func checkImage(report *doctorReport, cli *DebugCli) {
+	image := cli.config.Image
+	ctx, cancel := cli.withContent(cli.config.Timeout)
+	defer cancel()
+	inspect, _, err := cli.client.ImageInspectWithRaw(ctx, image)
+	if err == nil {
+		report.pass("image", "%s present (%s/%s)", image, inspect.Os, inspect.Architecture)
+		return
+	}
+	if !client.IsErrNotFound(err) {
+		report.warn("image", fmt.Sprintf("inspect %s: %s", image, err), "")
+		return
+	}
+	if cli.config.Pull == pullNever {
+		report.fail("image", fmt.Sprintf("%s not present and pull policy is never", image), "load it with `docker-debug image load ARCHIVE`")
+		return
+	}
+	domain, _ := splitDockerDomain(image)
+	auth, err := cli.RegistryAuth(domain)
+	if err != nil {
+		report.warn("image", fmt.Sprintf("registry credentials of %s: %s", domain, err), "check ~/.docker/config.json and the credential helpers")
+	}
+	encodedAuth, _ := registry.EncodeAuthConfig(auth)
+	if _, err = cli.client.DistributionInspect(ctx, image, encodedAuth); err != nil {
+		report.fail(
+			"image",
+			fmt.Sprintf("%s not present and not pullable: %s", image, err),
+			"`docker login "+domain+"`, set [registry.\""+domain+"\"] in config, or use --image-archive",
+		)
+		return
+	}
+	report.pass("image", "%s not present, pullable from %s", image, domain)
+}
+
+// checkTarget check the rootfs, namespaces and security profiles of the target
+func checkTarget(report *doctorReport, cli *DebugCli, daemon system.Info, target string) {
+	targetID, err := cli.FindContainer(target)
+	if err != nil {
+		report.fail("target", err.Error(), "list containers with `docker ps -a`, targets can be ID, name, label=k=v or compose:project/service")
+		return
+	}
+	ctx, cancel := cli.withContent(cli.config.Timeout)
+	info, err := cli.client.ContainerInspect(ctx, targetID)
+	cancel()
+	if err != nil {
+		report.fail("target", err.Error(), "")
+		return
+	}
+	name := trimContainerName(info.Name)
+	if info.State == nil || !info.State.Running {
+		report.fail("target", fmt.Sprintf("%s is not running", name), "debug a copy with `docker-debug --clone "+name+"`")
+	} else {
+		report.pass("target", "%s running", name)
+	}
+
+	mergedDir := ""
+	if info.GraphDriver.Data != nil {
+		mergedDir = info.GraphDriver.Data["MergedDir"]
+	}
+	switch {
+	case mergedDir != "" && cli.config.MountDir != "":
+		report.pass("rootfs", "%s driver, MergedDir mounted at %s", info.Driver, cli.config.MountDir)
+	case mergedDir != "":
+		report.warn("rootfs", "mount_dir is not set, the target rootfs is not mounted", "`docker-debug config set mount_dir /mnt/container`")
+	default:
+		report.warn(
+			"rootfs",
+			fmt.Sprintf("%s driver has no MergedDir, the rootfs falls back to /proc/<pid>/root", info.Driver),
+			"use --rootfs pid or --rootfs proc, `docker-debug cp` still works through the debug container",
+		)
+	}
+
+	daemonUserns := hasSecurityOption(daemon.SecurityOptions, "userns")
+	usernsMode := string(info.HostConfig.UsernsMode)
+	switch {
+	case daemonUserns && usernsMode != "host":
+		report.warn("userns", "daemon remaps user namespaces, files of the target show remapped uids", "--privileged is limited inside the remapped namespace, run the target with --userns=host to debug it fully")
+	case usernsMode == "host":
+		report.pass("userns", "host user namespace")
+	default:
+		report.pass("userns", "no user namespace remapping")
+	}
+
+	checkPtrace(report, daemon, info)
+}
+
+// checkPtrace check seccomp and AppArmor would let the debug container ptrace the target
+func checkPtrace(report *doctorReport, daemon system.Info, info types.ContainerJSON) {
+	securityOpts := strings.Join(info.HostConfig.SecurityOpt, " ")
+	seccomp := hasSecurityOption(daemon.SecurityOptions, "seccomp") && !strings.Contains(securityOpts, "seccomp=unconfined")
+	if seccomp {
+		report.pass("seccomp", "target runs with a seccomp profile")
+	} else {
+		report.pass("seccomp", "target is not confined by seccomp")
+	}
+	if profile := info.AppArmorProfile; profile != "" {
+		report.pass("apparmor", "target profile %s", profile)
+	}
+
+	var problems []string
+	hint := "add --cap-adds SYS_PTRACE to strace or gdb the target processes"
+	if hasSecurityOption(daemon.SecurityOptions, "seccomp") && kernelOlder(daemon.KernelVersion, 4, 8) {
+		problems = append(problems, fmt.Sprintf("the default seccomp profile blocks ptrace on kernel %s (< 4.8)", daemon.KernelVersion))
+		hint += " and --security-opts seccomp=unconfined"
+	}
+	switch profile := info.AppArmorProfile; profile {
+	case "", "unconfined", "docker-default":
+	default:
+		problems = append(problems, fmt.Sprintf("AppArmor profile %s of the target may deny ptrace from docker-default", profile))
+		hint += " and --security-opts apparmor=unconfined"
+	}
+	if info.HostConfig.Privileged {
+		report.pass("ptrace", "target is privileged, ptrace is allowed with --cap-adds SYS_PTRACE")
+		return
+	}
+	if len(problems) > 0 {
+		report.warn("ptrace", strings.Join(problems, ", "), hint)
+		return
+	}
+	report.pass("ptrace", "allowed with --cap-adds SYS_PTRACE")
+}
+
+// hasSecurityOption match name=<name> entries of docker info SecurityOptions
+func hasSecurityOption(options []string, name string) bool {
+	for _, opt := range options {
+		for _, field := range strings.Split(opt, ",") {
+			if field == "name="+name || field == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// kernelOlder report whether kernel (like 4.4.0-210-generic) is older than major.minor,
+// false when it can not be parsed
+func kernelOlder(kernel string, major, minor int) bool {
+	parts := strings.SplitN(kernel, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	kMajor, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	kMinor, err := strconv.Atoi(strings.TrimFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' }))
+	if err != nil {
+		return false
+	}
+	return kMajor < major || kMajor == major && kMinor < minor
+}
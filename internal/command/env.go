@@ -0,0 +1,94 @@
+package command
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// inheritEnvDeny target variables which would break the debug image tools, never inherited
+var inheritEnvDeny = []string{"PATH", "HOME", "HOSTNAME", "LD_LIBRARY_PATH"}
+
+// ResolveEnv returns the environment of the debug session: the target environment
+// when inherited (without denied keys), then env files, then --env,
+// it is only passed to the exec so it is not kept in the debug container config
+func (cli *DebugCli) ResolveEnv(options execOptions) ([]string, error) {
+	var env []string
+	if options.inheritEnv {
+		ctx, cancel := cli.withContent(cli.config.Timeout)
+		info, err := cli.client.ContainerInspect(ctx, options.container)
+		cancel()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		deny := append(append(append([]string{}, inheritEnvDeny...), cli.config.EnvDeny...), options.envDeny...)
+		for _, kv := range info.Config.Env {
+			if !envDenied(kv, deny) {
+				env = append(env, kv)
+			}
+		}
+	}
+	for _, file := range options.envFiles {
+		fileEnv, err := readEnvFile(file)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, fileEnv...)
+	}
+	for _, kv := range options.env {
+		if kv, ok := expandEnv(kv); ok {
+			env = append(env, kv)
+		}
+	}
+	return env, nil
+}
+
+// envDenied reports whether the key matches one of the glob patterns
+func envDenied(kv string, patterns []string) bool {
+	key, _, _ := strings.Cut(kv, "=")
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// expandEnv takes the value of a bare KEY from the local environment
+func expandEnv(kv string) (string, bool) {
+	if strings.Contains(kv, "=") {
+		return kv, true
+	}
+	value, ok := os.LookupEnv(kv)
+	if !ok {
+		return "", false
+	}
+	return kv + "=" + value, true
+}
+
+// readEnvFile read KEY=VALUE lines, empty lines and # comments are skipped
+func readEnvFile(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer file.Close()
+	var env []string
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if strings.HasPrefix(text, "=") {
+			return nil, errors.Errorf("%s:%d: invalid environment variable: `%s`", filename, line, text)
+		}
+		if kv, ok := expandEnv(text); ok {
+			env = append(env, kv)
+		}
+	}
+	return env, errors.WithStack(scanner.Err())
+}
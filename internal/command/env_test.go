@@ -0,0 +1,73 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadEnvFile(t *testing.T) {
+	t.Setenv("DOCKER_DEBUG_TEST_LOCAL", "local")
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		err     string
+	}{
+		{
+			name:    "values, comments and local keys",
+			content: "# comment\n\nA=1\n  B = 2 \nC=\nDOCKER_DEBUG_TEST_LOCAL\nDOCKER_DEBUG_TEST_UNSET\n",
+			want:    []string{"A=1", "B = 2", "C=", "DOCKER_DEBUG_TEST_LOCAL=local"},
+		},
+		{
+			name:    "empty key",
+			content: "A=1\n=2\n",
+			err:     ":2: invalid environment variable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "env")
+			if err := os.WriteFile(file, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readEnvFile(file)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("readEnvFile() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readEnvFile() error = %v", err)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("readEnvFile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvDenied(t *testing.T) {
+	deny := append(append([]string{}, inheritEnvDeny...), "*TOKEN*", "AWS_*")
+	tests := []struct {
+		kv     string
+		denied bool
+	}{
+		{"PATH=/usr/bin", true},
+		{"HOME=/root", true},
+		{"HOSTNAME=abc", true},
+		{"LD_LIBRARY_PATH=/opt/lib", true},
+		{"GITHUB_TOKEN=x", true},
+		{"AWS_SECRET_ACCESS_KEY=x", true},
+		{"MY_PATH=/x", false},
+		{"LANG=C.UTF-8", false},
+		{"TOKENIZER", true},
+	}
+	for _, tt := range tests {
+		if got := envDenied(tt.kv, deny); got != tt.denied {
+			t.Errorf("envDenied(%q) = %t, want %t", tt.kv, got, tt.denied)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type gcOptions struct {
+	execOptions
+	all    bool
+	dryRun bool
+	maxAge time.Duration
+}
+
+func init() {
+	options := gcOptions{execOptions: newExecOptions()}
+	cmd := &cobra.Command{
+		Use:   "gc [OPTIONS]",
+		Short: "Remove orphaned debug containers",
+		Long: `Remove debug containers when:
+  - the target container is gone or stopped
+  - the debug container is older than max age (gc_max_age in config, 0 disables)
+  - the client which started the session on this host is no longer running
+
+Sessions of clients on other hosts are only removed by max age.`,
+		Args: RequiresMinArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !cmd.Flags().Changed("max-age") {
+				options.maxAge = -1
+			}
+			return runGc(options)
+		},
+	}
+	flags := cmd.Flags()
+	addClientFlags(flags, &options.execOptions)
+	flags.BoolVarP(&options.all, "all", "a", false, "gc on all docker configs")
+	flags.BoolVar(&options.dryRun, "dry-run", false, "only show debug containers to remove")
+	flags.DurationVar(&options.maxAge, "max-age", 0, "remove debug containers older than this (default gc_max_age in config)")
+	rootCmd.AddCommand(cmd)
+}
+
+func runGc(options gcOptions) error {
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "HOST\tCONTAINER\tTARGET\tREASON\tRESULT")
+	err := eachHost(ctx, options.execOptions, options.all, func(host string, cli *DebugCli) error {
+		maxAge := options.maxAge
+		if maxAge < 0 {
+			maxAge = cli.Config().GCMaxAge
+		}
+		containers, err := cli.listContainers(sidecarFilters(""))
+		if err != nil {
+			return err
+		}
+		for _, c := range containers {
+			reason, err := cli.orphanReason(c, maxAge)
+			if err != nil {
+				return err
+			}
+			if reason == "" {
+				continue
+			}
+			result := "dry-run"
+			if !options.dryRun {
+				result = "removed"
+				if err = cli.ContainerRemove(c.ID); err != nil {
+					result = err.Error()
+				}
+			}
+			s := newSession(host, c)
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", host, c.ID[:shortContainerIDSize], s.TargetName, reason, result)
+		}
+		return cli.gcClones(w, host, options.dryRun)
+	})
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// orphanReason returns why the debug container should be removed, empty when it is in use
+func (cli *DebugCli) orphanReason(c types.Container, maxAge time.Duration) (string, error) {
+	ctx, cancel := cli.withContent(cli.config.Timeout)
+	info, err := cli.client.ContainerInspect(ctx, c.Labels[labelTarget])
+	cancel()
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return "target gone", nil
+		}
+		return "", errors.WithStack(err)
+	}
+	if !info.State.Running {
+		return "target stopped", nil
+	}
+	if maxAge > 0 {
+		s := newSession("", c)
+		if age := time.Since(s.Created); age > maxAge {
+			return fmt.Sprintf("older than %s", maxAge), nil
+		}
+	}
+	if c.Labels[labelKeep] == "true" {
+		return "", nil
+	}
+	if c.Labels[labelOwnerHost] == localHostname() {
+		pid, err := strconv.Atoi(c.Labels[labelOwnerPID])
+		if err == nil && !processAlive(pid) {
+			return "client exited", nil
+		}
+	}
+	return "", nil
+}
+
+// gcClones remove --clone copies which no debug container is attached to
+func (cli *DebugCli) gcClones(w io.Writer, host string, dryRun bool) error {
+	args := filters.NewArgs()
+	args.Add("label", labelCloneOf)
+	clones, err := cli.listContainers(args)
+	if err != nil {
+		return err
+	}
+	for _, c := range clones {
+		sidecars, err := cli.listContainers(sidecarFilters(c.ID))
+		if err != nil {
+			return err
+		}
+		if len(sidecars) > 0 {
+			continue
+		}
+		result := "dry-run"
+		if !dryRun {
+			result = "removed"
+			if err = cli.ContainerRemove(c.ID); err != nil {
+				result = err.Error()
+			} else {
+				cli.CloneClean("", c.ImageID)
+			}
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", host, c.ID[:shortContainerIDSize], c.Labels[labelTargetName], "unused clone", result)
+	}
+	return nil
+}
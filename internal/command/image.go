@@ -0,0 +1,181 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/docker/docker/api/types/versions"
+	"github.com/docker/docker/client"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+const (
+	pullAlways  = "always"
+	pullMissing = "missing"
+	pullNever   = "never"
+
+	// platformMinVersion api version of platform on container create
+	platformMinVersion = "1.41"
+)
+
+// addImageFlags debug image flags
+func addImageFlags(flags *pflag.FlagSet, options *execOptions) {
+	flags.StringVar(&options.pull, "pull", "", "Pull the debug image before running (always|missing|never, default pull in config or missing)")
+	flags.StringVar(&options.imageArchive, "image-archive", "", "Load the debug image from a docker save `file` instead of pulling it")
+	flags.StringVar(&options.platform, "platform", "", "Platform of the debug image (format: os/arch[/variant], default the target container platform)")
+	_ = flags.SetAnnotation("platform", "version", []string{platformMinVersion})
+}
+
+// pullPolicy returns the pull policy of the flag, config then missing
+func (cli *DebugCli) pullPolicy(policy string) (string, error) {
+	if policy == "" {
+		policy = cli.config.Pull
+	}
+	switch policy {
+	case "":
+		return pullMissing, nil
+	case pullAlways, pullMissing, pullNever:
+		return policy, nil
+	}
+	return "", errors.Errorf("invalid pull policy: `%s` (always|missing|never)", policy)
+}
+
+// parsePlatform parse os/arch[/variant]
+func parsePlatform(s string) (*ocispec.Platform, error) {
+	parts := strings.Split(strings.ToLower(s), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, errors.Errorf("invalid platform: `%s` (format: os/arch[/variant])", s)
+	}
+	p := &ocispec.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+	return p, nil
+}
+
+func formatPlatform(p *ocispec.Platform) string {
+	if p == nil {
+		return ""
+	}
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// matchPlatform reports whether the image platform satisfies want, an empty variant matches any,
+// variants are compared after normalizeVariant
+func matchPlatform(want *ocispec.Platform, os, arch, variant string) bool {
+	if want == nil {
+		return true
+	}
+	if want.OS != os || want.Architecture != arch {
+		return false
+	}
+	return want.Variant == "" || normalizeVariant(arch, want.Variant) == normalizeVariant(arch, variant)
+}
+
+// normalizeVariant fill the default variant of an architecture, as containerd does:
+// arm64 is v8, arm is v7 and amd64 v1 is no variant
+func normalizeVariant(arch, variant string) string {
+	switch arch {
+	case "arm64":
+		if variant == "" {
+			return "v8"
+		}
+	case "arm":
+		if variant == "" {
+			return "v7"
+		}
+	case "amd64":
+		if variant == "v1" {
+			return ""
+		}
+	}
+	return variant
+}
+
+// TargetPlatform returns the platform of the target container image
+func (cli *DebugCli) TargetPlatform(targetID string) (*ocispec.Platform, error) {
+	ctx, cancel := cli.withContent(cli.config.Timeout)
+	defer cancel()
+	info, err := cli.client.ContainerInspect(ctx, targetID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	image, _, err := cli.client.ImageInspectWithRaw(ctx, info.Image)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if image.Os == "" || image.Architecture == "" {
+		return nil, nil
+	}
+	return &ocispec.Platform{OS: image.Os, Architecture: image.Architecture, Variant: image.Variant}, nil
+}
+
+// resolvePlatform returns the debug image platform of the flag or the target container,
+// nil when the daemon api is too old to select a platform
+func (cli *DebugCli) resolvePlatform(options execOptions) (*ocispec.Platform, error) {
+	if options.platform != "" {
+		if versions.LessThan(cli.client.ClientVersion(), platformMinVersion) {
+			return nil, errors.Errorf("--platform requires api version %s or later", platformMinVersion)
+		}
+		return parsePlatform(options.platform)
+	}
+	if options.container == "" || versions.LessThan(cli.client.ClientVersion(), platformMinVersion) {
+		return nil, nil
+	}
+	p, err := cli.TargetPlatform(options.container)
+	if err != nil {
+		logrus.Debugf("target platform: %+v", err)
+		return nil, nil
+	}
+	return p, nil
+}
+
+// EnsureImage load the image archive or apply the pull policy to the debug image
+// of the target platform, the platform is kept for the debug containers created later
+func (cli *DebugCli) EnsureImage(options execOptions) error {
+	policy, err := cli.pullPolicy(options.pull)
+	if err != nil {
+		return err
+	}
+	platform, err := cli.resolvePlatform(options)
+	if err != nil {
+		return err
+	}
+	cli.platform = platform
+	if options.imageArchive != "" {
+		cli.config.Image, err = cli.LoadImage(options.imageArchive)
+		return err
+	}
+	if policy == pullAlways {
+		return cli.PullImage(cli.config.Image)
+	}
+
+	ctx, cancel := cli.withContent(cli.config.Timeout)
+	image, _, err := cli.client.ImageInspectWithRaw(ctx, cli.config.Image)
+	cancel()
+	switch {
+	case client.IsErrNotFound(err):
+		if policy == pullNever {
+			return errors.Errorf("image `%s` not found and pull policy is never", cli.config.Image)
+		}
+	case err != nil:
+		return errors.WithStack(err)
+	case matchPlatform(platform, image.Os, image.Architecture, image.Variant):
+		return nil
+	case policy == pullNever:
+		return errors.Errorf(
+			"image `%s` is %s/%s, %s is required and pull policy is never",
+			cli.config.Image,
+			image.Os,
+			image.Architecture,
+			formatPlatform(platform),
+		)
+	}
+	return cli.PullImage(cli.config.Image)
+}
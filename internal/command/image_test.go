@@ -0,0 +1,64 @@
+package command
+
+import (
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestParsePlatform(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+		err  bool
+	}{
+		{s: "linux/amd64", want: "linux/amd64"},
+		{s: "Linux/ARM64/v8", want: "linux/arm64/v8"},
+		{s: "linux/arm/v7", want: "linux/arm/v7"},
+		{s: "linux", err: true},
+		{s: "linux/", err: true},
+		{s: "/amd64", err: true},
+		{s: "linux/arm/v7/extra", err: true},
+	}
+	for _, tt := range tests {
+		p, err := parsePlatform(tt.s)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parsePlatform(%q) = %s, want error", tt.s, formatPlatform(p))
+			}
+			continue
+		}
+		if err != nil || formatPlatform(p) != tt.want {
+			t.Errorf("parsePlatform(%q) = %s, %v, want %s", tt.s, formatPlatform(p), err, tt.want)
+		}
+	}
+}
+
+func TestMatchPlatform(t *testing.T) {
+	arm := &ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}
+	arm64 := &ocispec.Platform{OS: "linux", Architecture: "arm64"}
+	arm64v8 := &ocispec.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}
+	amd64v1 := &ocispec.Platform{OS: "linux", Architecture: "amd64", Variant: "v1"}
+	tests := []struct {
+		want              *ocispec.Platform
+		os, arch, variant string
+		match             bool
+	}{
+		{nil, "linux", "amd64", "", true},
+		{arm64, "linux", "arm64", "v8", true},
+		{arm64, "linux", "amd64", "", false},
+		{arm, "linux", "arm", "v7", true},
+		{arm, "linux", "arm", "v6", false},
+		{arm, "windows", "arm", "v7", false},
+		{arm64v8, "linux", "arm64", "", true},
+		{arm64v8, "linux", "arm64", "v8", true},
+		{arm64v8, "linux", "arm64", "v9", false},
+		{arm, "linux", "arm", "", true},
+		{amd64v1, "linux", "amd64", "", true},
+	}
+	for _, tt := range tests {
+		if got := matchPlatform(tt.want, tt.os, tt.arch, tt.variant); got != tt.match {
+			t.Errorf("matchPlatform(%s, %s/%s/%s) = %t, want %t", formatPlatform(tt.want), tt.os, tt.arch, tt.variant, got, tt.match)
+		}
+	}
+}
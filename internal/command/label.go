@@ -0,0 +1,85 @@
+package command
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/zeromake/docker-debug/version"
+)
+
+const (
+	labelPrefix = "io.github.zeromake.docker-debug"
+	// labelTarget target container id of the debug container
+	labelTarget = labelPrefix + ".target"
+	// labelTargetName target container name of the debug container
+	labelTargetName = labelPrefix + ".target-name"
+	// labelKeep debug container is kept after the session exits
+	labelKeep = labelPrefix + ".keep"
+	// labelUser local user who started the debug container
+	labelUser = labelPrefix + ".user"
+	// labelCreated debug container start time (RFC3339)
+	labelCreated = labelPrefix + ".created"
+	// labelVersion docker-debug version which created the debug container
+	labelVersion = labelPrefix + ".version"
+	// labelPrivileged debug container is started with --privileged
+	labelPrivileged = labelPrefix + ".privileged"
+	// labelOwnerHost hostname of the client which holds the session lease
+	labelOwnerHost = labelPrefix + ".owner-host"
+	// labelRootfs strategy used to expose the target rootfs at MountDir
+	labelRootfs = labelPrefix + ".rootfs"
+	// labelCloneOf target container id of a copy created by --clone
+	labelCloneOf = labelPrefix + ".clone-of"
+	// labelOwnerPID pid of the client which holds the session lease
+	labelOwnerPID = labelPrefix + ".owner-pid"
+)
+
+// sidecarFilters filter debug containers, by target when targetID is not empty
+func sidecarFilters(targetID string) filters.Args {
+	args := filters.NewArgs()
+	if targetID == "" {
+		args.Add("label", labelTarget)
+	} else {
+		args.Add("label", labelTarget+"="+targetID)
+	}
+	return args
+}
+
+// sidecarLabels labels of a new debug container
+func sidecarLabels(target types.ContainerJSON, options execOptions) map[string]string {
+	labels := map[string]string{
+		labelTarget:     target.ID,
+		labelTargetName: trimContainerName(target.Name),
+		labelUser:       localUser(),
+		labelCreated:    time.Now().Format(time.RFC3339),
+		labelVersion:    version.Version,
+		labelPrivileged: strconv.FormatBool(options.privileged),
+	}
+	if options.keep {
+		labels[labelKeep] = "true"
+	} else {
+		labels[labelOwnerHost] = localHostname()
+		labels[labelOwnerPID] = strconv.Itoa(os.Getpid())
+	}
+	return labels
+}
+
+func localUser() string {
+	u, err := user.Current()
+	if err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+func localHostname() string {
+	name, _ := os.Hostname()
+	return name
+}
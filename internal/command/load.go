@@ -0,0 +1,175 @@
+package command
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/zeromake/docker-debug/internal/config"
+	"github.com/zeromake/docker-debug/pkg/stream"
+)
+
+const archiveManifestName = "manifest.json"
+
+// archiveManifest an entry of manifest.json in a `docker save` archive
+type archiveManifest struct {
+	Config   string
+	RepoTags []string
+}
+
+func init() {
+	options := newExecOptions()
+	var setDefault bool
+	imageCmd := &cobra.Command{
+		Use:   "image",
+		Short: "Manage the debug image",
+	}
+	loadCmd := &cobra.Command{
+		Use:   "load [OPTIONS] ARCHIVE",
+		Short: "Load the debug image from a `docker save` archive to the docker host",
+		Args:  RequiresMinArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImageLoad(options, args[0], setDefault)
+		},
+	}
+	flags := loadCmd.Flags()
+	flags.SetInterspersed(false)
+	addClientFlags(flags, &options)
+	flags.BoolVar(&setDefault, "default", false, "Save the loaded image as the default image in config")
+	imageCmd.AddCommand(loadCmd)
+	rootCmd.AddCommand(imageCmd)
+}
+
+func runImageLoad(options execOptions, archive string, setDefault bool) error {
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	cli, err := buildCli(ctx, options)
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	image, err := cli.LoadImage(archive)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cli.Out(), "Loaded image: %s\n", image)
+	if !setDefault {
+		return nil
+	}
+	conf, err := config.LoadFileConfig()
+	if err != nil {
+		return err
+	}
+	conf.Image = image
+	return conf.Save()
+}
+
+// LoadImage upload a `docker save` archive unless its image id already exists,
+// returns the first tag of the archive or the image id
+func (cli *DebugCli) LoadImage(archive string) (string, error) {
+	id, tags, err := readArchiveManifest(archive)
+	if err != nil {
+		return "", err
+	}
+	image := id
+	if len(tags) > 0 {
+		image = tags[0]
+	}
+
+	ctx, cancel := cli.withContent(cli.config.Timeout)
+	info, _, err := cli.client.ImageInspectWithRaw(ctx, id)
+	cancel()
+	if err == nil {
+		logrus.Debugf("image %s already loaded", id)
+		for _, tag := range info.RepoTags {
+			if tag == image {
+				return image, nil
+			}
+		}
+		return id, nil
+	}
+	if !client.IsErrNotFound(err) {
+		return "", errors.WithStack(err)
+	}
+
+	file, err := os.Open(archive)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer file.Close()
+	ctx, cancel = context.WithCancel(cli.ctx)
+	defer cancel()
+	resp, err := cli.client.ImageLoad(ctx, file, false)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	// progress goes to stderr like pulls
+	if resp.JSON {
+		err = jsonmessage.DisplayJSONMessagesToStream(resp.Body, stream.NewOutStream(cli.err), nil)
+	} else {
+		_, err = io.Copy(cli.err, resp.Body)
+	}
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return image, nil
+}
+
+// readArchiveManifest returns the image id and tags of the first image in a
+// `docker save` archive, gzip compressed archives are accepted
+func readArchiveManifest(archive string) (string, []string, error) {
+	file, err := os.Open(archive)
+	if err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+	defer file.Close()
+	var reader io.Reader = bufio.NewReader(file)
+	if magic, _ := reader.(*bufio.Reader).Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return "", nil, errors.WithStack(err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return "", nil, errors.Errorf("`%s` is not a docker save archive: %s not found", archive, archiveManifestName)
+		}
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "read archive `%s`", archive)
+		}
+		if path.Clean(hdr.Name) != archiveManifestName {
+			continue
+		}
+		var manifests []archiveManifest
+		if err = json.NewDecoder(tr).Decode(&manifests); err != nil {
+			return "", nil, errors.Wrapf(err, "parse %s of `%s`", archiveManifestName, archive)
+		}
+		if len(manifests) == 0 || manifests[0].Config == "" {
+			return "", nil, errors.Errorf("`%s` has no image", archive)
+		}
+		// blobs/sha256/<hex> in oci layout, <hex>.json in legacy archives
+		id := "sha256:" + strings.TrimSuffix(path.Base(manifests[0].Config), ".json")
+		return id, manifests[0].RepoTags, nil
+	}
+}
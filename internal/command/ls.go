@@ -0,0 +1,177 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/go-units"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/zeromake/docker-debug/internal/config"
+)
+
+type lsOptions struct {
+	execOptions
+	all    bool
+	format string
+}
+
+type session struct {
+	Host       string    `json:"host"`
+	ID         string    `json:"id"`
+	Target     string    `json:"target"`
+	TargetName string    `json:"target_name"`
+	Image      string    `json:"image"`
+	State      string    `json:"state"`
+	User       string    `json:"user"`
+	Created    time.Time `json:"created"`
+	Version    string    `json:"version"`
+	Privileged bool      `json:"privileged"`
+	Keep       bool      `json:"keep"`
+}
+
+func init() {
+	options := lsOptions{execOptions: newExecOptions()}
+	cmd := &cobra.Command{
+		Use:   "ls [OPTIONS]",
+		Short: "List debug containers",
+		Args:  RequiresMinArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLs(options)
+		},
+	}
+	flags := cmd.Flags()
+	addClientFlags(flags, &options.execOptions)
+	flags.BoolVarP(&options.all, "all", "a", false, "list on all docker configs")
+	flags.StringVarP(&options.format, "format", "f", "table", "output format (table|json)")
+	rootCmd.AddCommand(cmd)
+}
+
+func runLs(options lsOptions) error {
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	if options.format != "table" && options.format != "json" {
+		return errors.Errorf("unknown format: `%s`", options.format)
+	}
+
+	var sessions []session
+	err := eachHost(ctx, options.execOptions, options.all, func(host string, cli *DebugCli) error {
+		containers, err := cli.listContainers(sidecarFilters(""))
+		if err != nil {
+			return err
+		}
+		for _, c := range containers {
+			sessions = append(sessions, newSession(host, c))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if options.format == "json" {
+		if sessions == nil {
+			sessions = []session{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(sessions)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "HOST\tCONTAINER\tTARGET\tIMAGE\tAGE\tUSER\tPRIVILEGED\tKEEP\tSTATE")
+	for _, s := range sessions {
+		age := "-"
+		if !s.Created.IsZero() {
+			age = units.HumanDuration(time.Since(s.Created))
+		}
+		_, _ = fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%s\t%s\t%s\t%t\t%t\t%s\n",
+			s.Host,
+			s.ID[:shortContainerIDSize],
+			s.TargetName,
+			s.Image,
+			age,
+			s.User,
+			s.Privileged,
+			s.Keep,
+			s.State,
+		)
+	}
+	return w.Flush()
+}
+
+func newSession(host string, c types.Container) session {
+	s := session{
+		Host:       host,
+		ID:         c.ID,
+		Target:     c.Labels[labelTarget],
+		TargetName: c.Labels[labelTargetName],
+		Image:      c.Image,
+		State:      c.State,
+		User:       c.Labels[labelUser],
+		Version:    c.Labels[labelVersion],
+		Keep:       c.Labels[labelKeep] == "true",
+	}
+	s.Privileged, _ = strconv.ParseBool(c.Labels[labelPrivileged])
+	if created, err := time.Parse(time.RFC3339, c.Labels[labelCreated]); err == nil {
+		s.Created = created
+	} else {
+		s.Created = time.Unix(c.Created, 0)
+	}
+	if s.TargetName == "" && len(s.Target) >= shortContainerIDSize {
+		s.TargetName = s.Target[:shortContainerIDSize]
+	}
+	return s
+}
+
+// eachHost call fn with the selected docker config, or with every docker config when all is set
+func eachHost(ctx context.Context, options execOptions, all bool, fn func(host string, cli *DebugCli) error) error {
+	if !all {
+		cli, err := buildCli(ctx, options)
+		if err != nil {
+			return err
+		}
+		defer cli.Close()
+		host := options.host
+		if host == "" {
+			host = options.name
+		}
+		if host == "" {
+			host = cli.Config().DockerConfigDefault
+		}
+		return fn(host, cli)
+	}
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(conf.DockerConfig))
+	for name := range conf.DockerConfig {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cli, err := NewDebugCli(ctx, WithConfig(conf), WithClientConfig(conf.DockerConfig[name]))
+		if err == nil {
+			err = fn(name, cli)
+			cli.forgetStaleAPIVersion(err)
+			_ = cli.Close()
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		}
+	}
+	return nil
+}
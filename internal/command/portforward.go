@@ -0,0 +1,237 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type portForwardOptions struct {
+	execOptions
+	address       string
+	remoteAddress string
+}
+
+type portMapping struct {
+	local  string
+	remote string
+}
+
+func init() {
+	options := portForwardOptions{execOptions: newExecOptions()}
+	cmd := &cobra.Command{
+		Use:   "port-forward [OPTIONS] CONTAINER [LOCAL_PORT:]REMOTE_PORT [...]",
+		Short: "Forward local ports to ports in the target container network namespace",
+		Args:  RequiresMinArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.container = args[0]
+			mappings, err := parsePortMappings(args[1:])
+			if err != nil {
+				return err
+			}
+			return runPortForward(options, mappings)
+		},
+	}
+	flags := cmd.Flags()
+	flags.SetInterspersed(false)
+	flags.StringVarP(&options.image, "image", "i", "", "use this image")
+	addClientFlags(flags, &options.execOptions)
+	addImageFlags(flags, &options.execOptions)
+	flags.StringVar(&options.address, "address", "127.0.0.1", "local address to listen on")
+	flags.StringVar(&options.remoteAddress, "remote-address", "127.0.0.1", "address to connect to in the target network namespace")
+	rootCmd.AddCommand(cmd)
+}
+
+// parsePortMappings parse [LOCAL_PORT:]REMOTE_PORT, LOCAL_PORT can be empty to pick a random port
+func parsePortMappings(specs []string) ([]portMapping, error) {
+	mappings := make([]portMapping, 0, len(specs))
+	for _, spec := range specs {
+		local, remote, ok := strings.Cut(spec, ":")
+		if !ok {
+			local, remote = spec, spec
+		}
+		if local == "" {
+			local = "0"
+		}
+		for _, port := range []string{local, remote} {
+			if p, err := strconv.Atoi(port); err != nil || p < 0 || p > 65535 {
+				return nil, errors.Errorf("invalid port mapping: `%s`", spec)
+			}
+		}
+		if remote == "0" {
+			return nil, errors.Errorf("invalid port mapping: `%s`", spec)
+		}
+		mappings = append(mappings, portMapping{local: local, remote: remote})
+	}
+	return mappings, nil
+}
+
+func runPortForward(options portForwardOptions, mappings []portMapping) error {
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	cli, err := buildCli(ctx, options.execOptions)
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	options.container, err = cli.FindContainer(options.container)
+	if err != nil {
+		return err
+	}
+	sidecarID, cleanup, err := cli.networkSidecar(options.execOptions)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	relay, err := cli.findRelay(sidecarID)
+	if err != nil {
+		return err
+	}
+
+	var listeners []net.Listener
+	defer func() {
+		for _, l := range listeners {
+			_ = l.Close()
+		}
+	}()
+	for _, m := range mappings {
+		l, err := net.Listen("tcp", net.JoinHostPort(options.address, m.local))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		listeners = append(listeners, l)
+		_, _ = fmt.Fprintf(cli.Out(), "Forwarding from %s -> %s\n", l.Addr(), m.remote)
+		go cli.acceptForward(ctx, l, sidecarID, relay(options.remoteAddress, m.remote))
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- cli.WatchContainer(ctx, options.container)
+	}()
+	select {
+	case <-signals:
+		return nil
+	case err = <-errCh:
+		return err
+	}
+}
+
+// networkSidecar returns a running debug container in the target network namespace,
+// a temporary one is created when none is running
+func (cli *DebugCli) networkSidecar(options execOptions) (string, func(), error) {
+	sidecars, err := cli.listContainers(sidecarFilters(options.container))
+	if err != nil {
+		return "", nil, err
+	}
+	for _, c := range sidecars {
+		if c.State == "running" {
+			return c.ID, func() {}, nil
+		}
+	}
+	if err = cli.EnsureImage(options); err != nil {
+		return "", nil, err
+	}
+	options.keep = false
+	options.rootfs = rootfsNone
+	containerID, _, err := cli.CreateContainer(options.container, options)
+	if err != nil {
+		return "", nil, err
+	}
+	return containerID, func() {
+		if err := cli.ContainerClean(cli.ctx, containerID); err != nil {
+			logrus.Debugf("%+v", err)
+		}
+	}, nil
+}
+
+// findRelay returns the relay command builder for the tool found in the debug container
+func (cli *DebugCli) findRelay(containerID string) (func(host, port string) []string, error) {
+	out, err := cli.execOutput(containerID, []string{"sh", "-c", "command -v socat || command -v nc"})
+	if err != nil {
+		return nil, errors.Wrapf(err, "image `%s` has neither socat nor nc", cli.config.Image)
+	}
+	if strings.HasSuffix(strings.TrimSpace(out), "socat") {
+		return func(host, port string) []string {
+			return []string{"socat", "-", "TCP:" + net.JoinHostPort(host, port)}
+		}, nil
+	}
+	return func(host, port string) []string {
+		return []string{"nc", host, port}
+	}, nil
+}
+
+func (cli *DebugCli) acceptForward(ctx context.Context, l net.Listener, containerID string, relay []string) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			logrus.Debugf("%+v", err)
+			return
+		}
+		go func() {
+			defer conn.Close()
+			if err := cli.forward(ctx, conn, containerID, relay); err != nil {
+				_, _ = fmt.Fprintf(cli.Err(), "Forward %s: %s\n", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// forward stream the connection through a hijacked exec of the relay
+func (cli *DebugCli) forward(ctx context.Context, conn net.Conn, containerID string, relay []string) error {
+	createCtx, cancel := cli.withContent(cli.config.Timeout)
+	resp, err := cli.client.ContainerExecCreate(createCtx, containerID, container.ExecOptions{
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          relay,
+	})
+	cancel()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	attach, err := cli.client.ContainerExecAttach(ctx, resp.ID, container.ExecStartOptions{})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer attach.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(attach.Conn, conn)
+		_ = attach.CloseWrite()
+	}()
+	var stderr bytes.Buffer
+	_, err = stdcopy.StdCopy(conn, &stderr, attach.Reader)
+	_ = conn.Close()
+	wg.Wait()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return errors.New(msg)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package command
+
+import "testing"
+
+func TestParsePortMappings(t *testing.T) {
+	tests := []struct {
+		spec          string
+		local, remote string
+		err           bool
+	}{
+		{spec: "8080", local: "8080", remote: "8080"},
+		{spec: "9000:80", local: "9000", remote: "80"},
+		{spec: ":80", local: "0", remote: "80"},
+		{spec: "0:80", local: "0", remote: "80"},
+		{spec: "80:0", err: true},
+		{spec: "0", err: true},
+		{spec: "http", err: true},
+		{spec: "70000:80", err: true},
+		{spec: "-1:80", err: true},
+	}
+	for _, tt := range tests {
+		mappings, err := parsePortMappings([]string{tt.spec})
+		if tt.err {
+			if err == nil {
+				t.Errorf("parsePortMappings(%q) = %v, want error", tt.spec, mappings)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parsePortMappings(%q) error = %v", tt.spec, err)
+			continue
+		}
+		if mappings[0].local != tt.local || mappings[0].remote != tt.remote {
+			t.Errorf("parsePortMappings(%q) = %s:%s, want %s:%s", tt.spec, mappings[0].local, mappings[0].remote, tt.local, tt.remote)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+// +build !windows
+
+package command
+
+import (
+	"syscall"
+)
+
+// processAlive report whether a local process is running
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
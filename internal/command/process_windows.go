@@ -0,0 +1,26 @@
+//go:build windows
+// +build windows
+
+package command
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// stillActive exit code of a running process (STILL_ACTIVE)
+const stillActive = 259
+
+// processAlive report whether a local process is running
+func processAlive(pid int) bool {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// a process of another user can not be opened, it is alive
+		return err == windows.ERROR_ACCESS_DENIED
+	}
+	defer windows.CloseHandle(h)
+	var code uint32
+	if err = windows.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
@@ -0,0 +1,67 @@
+package command
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zeromake/docker-debug/internal/config"
+)
+
+// applyProfile fill options from the selected profile, flags set on the command line win
+func applyProfile(flags *pflag.FlagSet, options *execOptions) error {
+	if options.profile == "" {
+		return nil
+	}
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	p, ok := conf.Profiles[options.profile]
+	if !ok {
+		return errors.Errorf("not find %s profile", options.profile)
+	}
+	setString := func(name string, dst *string, value string) {
+		if value != "" && !flags.Changed(name) {
+			*dst = value
+		}
+	}
+	setBool := func(name string, dst *bool, value bool) {
+		if value && !flags.Changed(name) {
+			*dst = value
+		}
+	}
+	setSlice := func(name string, dst *[]string, value []string) {
+		if len(value) > 0 && !flags.Changed(name) {
+			*dst = value
+		}
+	}
+	setString("image", &options.image, p.Image)
+	setString("name", &options.name, p.Config)
+	setString("host", &options.host, p.Host)
+	setString("context", &options.context, p.Context)
+	setString("cert-dir", &options.certDir, p.CertDir)
+	setString("detach-keys", &options.detachKeys, p.DetachKeys)
+	setString("user", &options.user, p.User)
+	setBool("privileged", &options.privileged, p.Privileged)
+	setString("work-dir", &options.workDir, p.WorkDir)
+	setString("target-dir", &options.targetDir, p.TargetDir)
+	setSlice("volume", &options.volumes, p.Volumes)
+	setBool("ipc", &options.ipc, p.IPC)
+	setSlice("security-opts", &options.securityOpts, p.SecurityOpts)
+	setSlice("cap-adds", &options.capAdds, p.CapAdds)
+	setBool("no-tty", &options.noTty, p.NoTTY)
+	setString("record", &options.record, p.Record)
+	setBool("keep", &options.keep, p.Keep)
+	setBool("clone", &options.clone, p.Clone)
+	setString("rootfs", &options.rootfs, p.Rootfs)
+	setSlice("env", &options.env, p.Env)
+	setSlice("env-file", &options.envFiles, p.EnvFiles)
+	setBool("inherit-env", &options.inheritEnv, p.InheritEnv)
+	setSlice("env-deny", &options.envDeny, p.EnvDeny)
+	setBool("as-target", &options.asTarget, p.AsTarget)
+	setSlice("entrypoint", &options.entrypoint, p.Entrypoint)
+	setString("pull", &options.pull, p.Pull)
+	setString("platform", &options.platform, p.Platform)
+	setString("image-archive", &options.imageArchive, p.ImageArchive)
+	return nil
+}
@@ -0,0 +1,84 @@
+package command
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/registry"
+	"github.com/moby/term"
+	"github.com/pkg/errors"
+
+	"github.com/zeromake/docker-debug/pkg/credentials"
+)
+
+// RegistryAuth resolve credentials of the registry domain,
+// config.toml registry entries win over ~/.docker/config.json
+func (cli *DebugCli) RegistryAuth(domain string) (registry.AuthConfig, error) {
+	server := credentials.ServerAddress(domain)
+	for _, key := range []string{domain, server} {
+		if auth, ok := cli.config.Registries[key]; ok && auth != nil {
+			return registry.AuthConfig{
+				Username:      auth.Username,
+				Password:      auth.Password,
+				IdentityToken: auth.IdentityToken,
+				ServerAddress: server,
+			}, nil
+		}
+	}
+	file, err := credentials.Load(credentials.Dir())
+	if err != nil {
+		return registry.AuthConfig{ServerAddress: server}, err
+	}
+	return file.Get(domain)
+}
+
+// registryPrivilegeFunc prompt credentials when the registry requires authentication
+func (cli *DebugCli) registryPrivilegeFunc(domain string) func(context.Context) (string, error) {
+	return func(context.Context) (string, error) {
+		if !cli.in.IsTerminal() {
+			return "", errors.Errorf(
+				"registry `%s` requires authentication, run `docker login %s` or set [registry.\"%s\"] in config",
+				domain, domain, domain,
+			)
+		}
+		auth, err := cli.promptAuth(domain)
+		if err != nil {
+			return "", err
+		}
+		return registry.EncodeAuthConfig(auth)
+	}
+}
+
+// promptAuth read username and password from the terminal, the password is not echoed
+func (cli *DebugCli) promptAuth(domain string) (registry.AuthConfig, error) {
+	auth := registry.AuthConfig{ServerAddress: credentials.ServerAddress(domain)}
+	reader := bufio.NewReader(cli.in)
+	_, _ = fmt.Fprintf(cli.err, "Authenticating with %s\nUsername: ", domain)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return auth, errors.WithStack(err)
+	}
+	auth.Username = strings.TrimSpace(line)
+
+	state, err := term.SaveState(cli.in.FD())
+	if err != nil {
+		return auth, errors.WithStack(err)
+	}
+	_, _ = fmt.Fprint(cli.err, "Password: ")
+	if err = term.DisableEcho(cli.in.FD(), state); err != nil {
+		return auth, errors.WithStack(err)
+	}
+	line, err = reader.ReadString('\n')
+	_ = term.RestoreTerminal(cli.in.FD(), state)
+	_, _ = fmt.Fprintln(cli.err)
+	if err != nil {
+		return auth, errors.WithStack(err)
+	}
+	auth.Password = strings.TrimRight(line, "\r\n")
+	if auth.Username == "" || auth.Password == "" {
+		return auth, errors.New("username and password are required")
+	}
+	return auth, nil
+}
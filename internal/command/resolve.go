@@ -0,0 +1,189 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/pkg/errors"
+)
+
+const (
+	labelSelectorPrefix  = "label="
+	composeTargetPrefix  = "compose:"
+	composeProjectLabel  = "com.docker.compose.project"
+	composeServiceLabel  = "com.docker.compose.service"
+	composeReplicaLabel  = "com.docker.compose.container-number"
+	shortContainerIDSize = 12
+)
+
+// FindContainer resolve target to a container id, target can be:
+//   - a full or unique prefix of a container id or name
+//   - label=key=value
+//   - compose:project/service[#replica]
+//
+// an ambiguous target shows a picker when running on a terminal
+func (cli *DebugCli) FindContainer(target string) (string, error) {
+	var (
+		candidates []types.Container
+		err        error
+	)
+	switch {
+	case strings.HasPrefix(target, labelSelectorPrefix):
+		args := filters.NewArgs()
+		args.Add("label", strings.TrimPrefix(target, labelSelectorPrefix))
+		candidates, err = cli.listContainers(args)
+	case strings.HasPrefix(target, composeTargetPrefix):
+		var args filters.Args
+		args, err = composeFilters(strings.TrimPrefix(target, composeTargetPrefix))
+		if err != nil {
+			return "", err
+		}
+		candidates, err = cli.listContainers(args)
+	default:
+		ctx, cancel := cli.withContent(cli.config.Timeout)
+		info, inspectErr := cli.client.ContainerInspect(ctx, target)
+		cancel()
+		if inspectErr == nil {
+			return info.ID, nil
+		}
+		candidates, err = cli.listContainers(filters.NewArgs())
+		candidates = matchContainerPrefix(candidates, target)
+	}
+	if err != nil {
+		return "", err
+	}
+	switch len(candidates) {
+	case 0:
+		return "", errors.Errorf("container: `%s` not found", target)
+	case 1:
+		return candidates[0].ID, nil
+	}
+	if !cli.in.IsTerminal() || !cli.out.IsTerminal() {
+		return "", errors.Errorf(
+			"container: `%s` is ambiguous, candidates:\n%s",
+			target,
+			formatCandidates(candidates),
+		)
+	}
+	return cli.pickContainer(candidates)
+}
+
+func (cli *DebugCli) listContainers(args filters.Args) ([]types.Container, error) {
+	ctx, cancel := cli.withContent(cli.config.Timeout)
+	defer cancel()
+	containers, err := cli.client.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: args,
+	})
+	return containers, errors.WithStack(err)
+}
+
+func (cli *DebugCli) pickContainer(candidates []types.Container) (string, error) {
+	_, _ = fmt.Fprint(cli.out, formatCandidates(candidates))
+	reader := bufio.NewReader(cli.in)
+	for {
+		_, _ = fmt.Fprintf(cli.out, "Select a container [1-%d]: ", len(candidates))
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		i, err := strconv.Atoi(strings.TrimSpace(line))
+		if err == nil && i >= 1 && i <= len(candidates) {
+			return candidates[i-1].ID, nil
+		}
+	}
+}
+
+// composeFilters parse project/service[#replica] to label filters
+func composeFilters(target string) (filters.Args, error) {
+	args := filters.NewArgs()
+	var replica string
+	if i := strings.LastIndex(target, "#"); i != -1 {
+		target, replica = target[:i], target[i+1:]
+		if _, err := strconv.Atoi(replica); err != nil {
+			return args, errors.Errorf("invalid compose replica: `%s`", replica)
+		}
+	}
+	project, service, ok := strings.Cut(target, "/")
+	if !ok || project == "" || service == "" {
+		return args, errors.Errorf("invalid compose target: `%s` (format: compose:project/service[#replica])", target)
+	}
+	args.Add("label", composeProjectLabel+"="+project)
+	args.Add("label", composeServiceLabel+"="+service)
+	if replica != "" {
+		args.Add("label", composeReplicaLabel+"="+replica)
+	}
+	return args, nil
+}
+
+// matchContainerPrefix match id or name prefix, an exact name wins,
+// debug containers and clones are skipped (an exact id or name still resolves them)
+func matchContainerPrefix(containers []types.Container, prefix string) []types.Container {
+	var matched []types.Container
+	targets := containers[:0:0]
+	for _, c := range containers {
+		if !isDebugContainer(c) {
+			targets = append(targets, c)
+		}
+	}
+	containers = targets
+	for _, c := range containers {
+		for _, name := range c.Names {
+			if strings.TrimPrefix(name, "/") == prefix {
+				return []types.Container{c}
+			}
+		}
+	}
+	for _, c := range containers {
+		if strings.HasPrefix(c.ID, prefix) {
+			matched = append(matched, c)
+			continue
+		}
+		for _, name := range c.Names {
+			if strings.HasPrefix(strings.TrimPrefix(name, "/"), prefix) {
+				matched = append(matched, c)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// isDebugContainer report whether the container was created by docker-debug
+func isDebugContainer(c types.Container) bool {
+	_, sidecar := c.Labels[labelTarget]
+	_, clone := c.Labels[labelCloneOf]
+	return sidecar || clone
+}
+
+func containerName(c types.Container) string {
+	if len(c.Names) == 0 {
+		return ""
+	}
+	return trimContainerName(c.Names[0])
+}
+
+func trimContainerName(name string) string {
+	return strings.TrimPrefix(name, "/")
+}
+
+func formatCandidates(candidates []types.Container) string {
+	var b strings.Builder
+	for i, c := range candidates {
+		_, _ = fmt.Fprintf(
+			&b,
+			"  %d) %s\t%s\t%s\t%s\n",
+			i+1,
+			c.ID[:shortContainerIDSize],
+			containerName(c),
+			c.Image,
+			c.Status,
+		)
+	}
+	return b.String()
+}
@@ -0,0 +1,71 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestMatchContainerPrefix(t *testing.T) {
+	containers := []types.Container{
+		{ID: "abc123", Names: []string{"/web"}},
+		{ID: "abd456", Names: []string{"/web-1"}},
+		{ID: "abe789", Names: []string{"/worker"}},
+		{ID: "abf000", Names: []string{"/web-debug"}, Labels: map[string]string{labelTarget: "abc123"}},
+		{ID: "abf111", Names: []string{"/web-clone"}, Labels: map[string]string{labelCloneOf: "abc123"}},
+	}
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"web", []string{"abc123"}},
+		{"web-", []string{"abd456"}},
+		{"wor", []string{"abe789"}},
+		{"ab", []string{"abc123", "abd456", "abe789"}},
+		{"abf", nil},
+		{"web-clone", nil},
+		{"nothing", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, c := range matchContainerPrefix(containers, tt.prefix) {
+			got = append(got, c.ID)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("matchContainerPrefix(%q) = %v, want %v", tt.prefix, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("matchContainerPrefix(%q) = %v, want %v", tt.prefix, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestComposeFilters(t *testing.T) {
+	tests := []struct {
+		target string
+		labels []string
+		err    bool
+	}{
+		{"shop/web", []string{composeProjectLabel + "=shop", composeServiceLabel + "=web"}, false},
+		{"shop/web#2", []string{composeProjectLabel + "=shop", composeServiceLabel + "=web", composeReplicaLabel + "=2"}, false},
+		{"shop/web#x", nil, true},
+		{"shop", nil, true},
+		{"/web", nil, true},
+	}
+	for _, tt := range tests {
+		args, err := composeFilters(tt.target)
+		if (err != nil) != tt.err {
+			t.Errorf("composeFilters(%q) error = %v, want error %t", tt.target, err, tt.err)
+			continue
+		}
+		for _, label := range tt.labels {
+			if !args.ExactMatch("label", label) {
+				t.Errorf("composeFilters(%q) misses label %s", tt.target, label)
+			}
+		}
+	}
+}
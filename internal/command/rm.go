@@ -0,0 +1,103 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	options := newExecOptions()
+	cmd := &cobra.Command{
+		Use:   "rm [OPTIONS] CONTAINER",
+		Short: "Remove the kept debug containers of a target container",
+		Long: `Remove the kept debug containers of a target container,
+a removed target is still matched by its id prefix or name.`,
+		Args: RequiresMinArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.container = args[0]
+			return runRm(options)
+		},
+	}
+	flags := cmd.Flags()
+	flags.SetInterspersed(false)
+	addClientFlags(flags, &options)
+	rootCmd.AddCommand(cmd)
+}
+
+func runRm(options execOptions) error {
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	cli, err := buildCli(ctx, options)
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	var sidecars []types.Container
+	targetID, err := cli.FindContainer(options.container)
+	if err == nil {
+		options.container = targetID
+		sidecars, err = cli.FindSidecars(targetID)
+	} else {
+		// the target may be deleted, its kept debug containers still carry its id and name
+		sidecars, err = cli.findOrphanSidecars(options.container, err)
+	}
+	if err != nil {
+		return err
+	}
+	if len(sidecars) == 0 {
+		return errors.Errorf("container: `%s` has no kept debug container", options.container[:shortContainerIDSize])
+	}
+	for _, sidecar := range sidecars {
+		if err = cli.ContainerRemove(sidecar.ID); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(cli.Out(), sidecar.ID[:shortContainerIDSize])
+	}
+	return nil
+}
+
+// findOrphanSidecars returns the kept debug containers of a removed target, findErr when there is none
+func (cli *DebugCli) findOrphanSidecars(target string, findErr error) ([]types.Container, error) {
+	kept, err := cli.FindSidecars("")
+	if err != nil {
+		return nil, err
+	}
+	sidecars, err := matchSidecarTarget(kept, target)
+	if err != nil {
+		return nil, err
+	}
+	if len(sidecars) == 0 {
+		return nil, findErr
+	}
+	return sidecars, nil
+}
+
+// matchSidecarTarget match debug containers by the id prefix or the name of their target,
+// an error when they belong to more than one target
+func matchSidecarTarget(sidecars []types.Container, target string) ([]types.Container, error) {
+	var matched []types.Container
+	if target == "" {
+		return nil, nil
+	}
+	targets := map[string]bool{}
+	for _, c := range sidecars {
+		if strings.HasPrefix(c.Labels[labelTarget], target) || c.Labels[labelTargetName] == trimContainerName(target) {
+			matched = append(matched, c)
+			targets[c.Labels[labelTarget]] = true
+		}
+	}
+	if len(targets) > 1 {
+		return nil, errors.Errorf("container: `%s` matches the debug containers of %d targets", target, len(targets))
+	}
+	return matched, nil
+}
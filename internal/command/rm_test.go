@@ -0,0 +1,51 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestMatchSidecarTarget(t *testing.T) {
+	sidecar := func(id, targetID, targetName string) types.Container {
+		return types.Container{ID: id, Labels: map[string]string{labelTarget: targetID, labelTargetName: targetName}}
+	}
+	sidecars := []types.Container{
+		sidecar("s1", "abc123", "web"),
+		sidecar("s2", "abc123", "web"),
+		sidecar("s3", "abd456", "db"),
+	}
+	tests := []struct {
+		target string
+		ids    string
+		err    string
+	}{
+		{target: "abc", ids: "s1,s2"},
+		{target: "web", ids: "s1,s2"},
+		{target: "/db", ids: "s3"},
+		{target: "zzz"},
+		{target: ""},
+		{target: "ab", err: "2 targets"},
+	}
+	for _, tt := range tests {
+		matched, err := matchSidecarTarget(sidecars, tt.target)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("matchSidecarTarget(%q) error = %v, want %q", tt.target, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("matchSidecarTarget(%q) error = %v", tt.target, err)
+			continue
+		}
+		var ids []string
+		for _, c := range matched {
+			ids = append(ids, c.ID)
+		}
+		if got := strings.Join(ids, ","); got != tt.ids {
+			t.Errorf("matchSidecarTarget(%q) = %s, want %s", tt.target, got, tt.ids)
+		}
+	}
+}
@@ -2,16 +2,50 @@ package command
 
 import (
 	"context"
+	"fmt"
+	"os"
 
+	"github.com/docker/docker/api/types/versions"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
 	"github.com/zeromake/docker-debug/internal/config"
 )
 
 var rootCmd = newExecCommand()
 
+// configFile --config flag, applied before any command loads the config
+var configFile string
+
+// commandFlags flags of the running command, checked against the api version in buildCli
+var commandFlags *pflag.FlagSet
+
+// commandCli cli built for the running command, Execute drops its cached api version when rejected
+var commandCli *DebugCli
+
+func init() {
+	// errors are printed by Execute, the usage only for invalid arguments
+	rootCmd.SilenceErrors = true
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		commandFlags = cmd.Flags()
+		return nil
+	}
+	rootCmd.PersistentFlags().StringVar(
+		&configFile,
+		"config",
+		"",
+		"config file (default $"+config.EnvConfigFile+", $XDG_CONFIG_HOME/docker-debug/config.toml or ~/.docker-debug/config.toml)",
+	)
+	cobra.OnInitialize(func() {
+		if configFile != "" {
+			config.SetFile(configFile)
+		}
+	})
+}
+
 type execOptions struct {
 	host         string
 	image        string
@@ -28,6 +62,23 @@ type execOptions struct {
 	ipc          bool
 	securityOpts []string
 	capAdds      []string
+	noTty        bool
+	tty          bool
+	record       string
+	keep         bool
+	clone        bool
+	rootfs       string
+	profile      string
+	env          []string
+	entrypoint   []string
+	envFiles     []string
+	inheritEnv   bool
+	envDeny      []string
+	asTarget     bool
+	pull         string
+	platform     string
+	imageArchive string
+	context      string
 }
 
 func newExecOptions() execOptions {
@@ -38,12 +89,15 @@ func newExecCommand() *cobra.Command {
 	options := newExecOptions()
 
 	cmd := &cobra.Command{
-		Use:   "docker-debug [OPTIONS] CONTAINER COMMAND [ARG...]",
+		Use:   "docker-debug [OPTIONS] CONTAINER [COMMAND] [ARG...]",
 		Short: "Run a command in a running container",
-		Args:  RequiresMinArgs(2),
+		Args:  RequiresMinArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			options.container = args[0]
 			options.command = args[1:]
+			if err := applyProfile(cmd.Flags(), &options); err != nil {
+				return err
+			}
 			return runExec(options)
 		},
 	}
@@ -53,19 +107,43 @@ func newExecCommand() *cobra.Command {
 
 	flags.StringArrayVarP(&options.volumes, "volume", "v", nil, "Attach a filesystem mount to the container")
 	flags.StringVarP(&options.image, "image", "i", "", "use this image")
+	addClientFlags(flags, &options)
+	addImageFlags(flags, &options)
+	addExecFlags(flags, &options)
+	flags.StringArrayVarP(&options.securityOpts, "security-opts", "s", nil, "Add security options to the Docker container")
+	flags.StringArrayVarP(&options.capAdds, "cap-adds", "C", nil, "Add Linux capabilities to the Docker container")
+	flags.BoolVar(&options.ipc, "ipc", false, "share target container ipc")
+	flags.BoolVar(&options.keep, "keep", false, "Keep the debug container running after exit, reattach with the attach command")
+	flags.Var(newEntrypointValue(&options.entrypoint), "entrypoint", "Override the keep-alive entrypoint of the debug container")
+	flags.StringVar(&options.rootfs, "rootfs", rootfsAuto, "How to expose the target filesystem at mount_dir (auto|merged|pid|proc|none)")
+	flags.BoolVar(&options.clone, "clone", false, "Debug a copy of the container with the entrypoint replaced by a shell (for stopped or crash-looping containers)")
+	return cmd
+}
+
+// addClientFlags docker connection flags
+func addClientFlags(flags *pflag.FlagSet, options *execOptions) {
 	flags.StringVarP(&options.name, "name", "n", "", "docker config name")
-	flags.StringVarP(&options.host, "host", "H", "", "connection host's docker (format: tcp://192.168.99.100:2376)")
+	flags.StringVarP(&options.host, "host", "H", "", "connection host's docker (format: tcp://192.168.99.100:2376 or ssh://user@host[:port])")
 	flags.StringVarP(&options.certDir, "cert-dir", "c", "", "cert dir use tls")
+	flags.StringVar(&options.context, "context", "", "docker cli context name (default DOCKER_CONTEXT when no config name is set)")
+}
+
+// addExecFlags exec session flags
+func addExecFlags(flags *pflag.FlagSet, options *execOptions) {
 	flags.StringVarP(&options.detachKeys, "detach-keys", "d", "", "Override the key sequence for detaching a container")
 	flags.StringVarP(&options.user, "user", "u", "", "Username or UID (format: <name|uid>[:<group|gid>])")
 	flags.BoolVarP(&options.privileged, "privileged", "p", false, "Give extended privileges to the command")
 	flags.StringVarP(&options.workDir, "work-dir", "w", "", "Working directory inside the container")
 	_ = flags.SetAnnotation("work-dir", "version", []string{"1.35"})
 	flags.StringVarP(&options.targetDir, "target-dir", "t", "", "Working directory inside the container")
-	flags.StringArrayVarP(&options.securityOpts, "security-opts", "s", nil, "Add security options to the Docker container")
-	flags.StringArrayVarP(&options.capAdds, "cap-adds", "C", nil, "Add Linux capabilities to the Docker container")
-	flags.BoolVar(&options.ipc, "ipc", false, "share target container ipc")
-	return cmd
+	flags.BoolVarP(&options.noTty, "no-tty", "T", false, "Disable pseudo-TTY allocation (auto when stdin or stdout is not a terminal)")
+	flags.StringVar(&options.record, "record", "", "Record the session output to an asciicast v2 file")
+	flags.StringVarP(&options.profile, "profile", "P", "", "use the named profile in config")
+	flags.BoolVar(&options.asTarget, "as-target", false, "Default the user and working directory to the target container")
+	flags.StringArrayVarP(&options.env, "env", "e", nil, "Set environment variables (format: KEY=VALUE or KEY to take the local value)")
+	flags.StringArrayVar(&options.envFiles, "env-file", nil, "Read in a file of environment variables")
+	flags.BoolVar(&options.inheritEnv, "inherit-env", false, "Inherit the environment variables of the target container (except PATH, HOME, HOSTNAME and LD_LIBRARY_PATH)")
+	flags.StringArrayVar(&options.envDeny, "env-deny", nil, "Glob of inherited environment variables to exclude (e.g. '*PASSWORD*')")
 }
 
 func buildCli(ctx context.Context, options execOptions) (*DebugCli, error) {
@@ -82,6 +160,26 @@ func buildCli(ctx context.Context, options execOptions) (*DebugCli, error) {
 	if conf.Image == "" {
 		return nil, errors.New("not set image")
 	}
+	dockerConfig, err := selectDockerConfig(conf, options)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, WithClientConfig(dockerConfig))
+
+	cli, err := NewDebugCli(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkFlagVersions(commandFlags, cli.client.ClientVersion()); err != nil {
+		_ = cli.Close()
+		return nil, err
+	}
+	commandCli = cli
+	return cli, nil
+}
+
+// selectDockerConfig returns the docker config of -H, --context (or DOCKER_CONTEXT), -n or the default one
+func selectDockerConfig(conf *config.Config, options execOptions) (*config.DockerConfig, error) {
 	if options.host != "" {
 		dockerConfig := &config.DockerConfig{
 			Host: options.host,
@@ -90,20 +188,54 @@ func buildCli(ctx context.Context, options execOptions) (*DebugCli, error) {
 			dockerConfig.TLS = true
 			dockerConfig.CertDir = options.certDir
 		}
-		opts = append(opts, WithClientConfig(dockerConfig))
-	} else {
-		name := conf.DockerConfigDefault
-		if options.name != "" {
-			name = options.name
+		return dockerConfig, nil
+	}
+	if contextName := dockerContextName(options); contextName != "" {
+		return resolveDockerContext(contextName)
+	}
+	name := conf.DockerConfigDefault
+	if options.name != "" {
+		name = options.name
+	}
+	dockerConfig, ok := conf.DockerConfig[name]
+	if !ok {
+		return nil, errors.Errorf("not find %s docker config", name)
+	}
+	return dockerConfig, nil
+}
+
+// checkFlagVersions returns an error for set flags annotated with a newer api version
+func checkFlagVersions(flags *pflag.FlagSet, apiVersion string) error {
+	if flags == nil {
+		return nil
+	}
+	var err error
+	flags.Visit(func(f *pflag.Flag) {
+		if err != nil {
+			return
 		}
-		opt, ok := conf.DockerConfig[name]
-		if !ok {
-			return nil, errors.Errorf("not find %s docker config", name)
+		if minVersion, ok := f.Annotations["version"]; ok && len(minVersion) > 0 &&
+			versions.LessThan(apiVersion, minVersion[0]) {
+			err = errors.Errorf(
+				"--%s requires docker api version %s, but the docker daemon api version is %s",
+				f.Name,
+				minVersion[0],
+				apiVersion,
+			)
 		}
-		opts = append(opts, WithClientConfig(opt))
-	}
+	})
+	return err
+}
 
-	return NewDebugCli(ctx, opts...)
+// dockerContextName returns --context, or DOCKER_CONTEXT when no docker config is selected
+func dockerContextName(options execOptions) string {
+	if options.context != "" {
+		return options.context
+	}
+	if options.name == "" {
+		return os.Getenv("DOCKER_CONTEXT")
+	}
+	return ""
 }
 
 func runExec(options execOptions) error {
@@ -117,33 +249,80 @@ func runExec(options execOptions) error {
 	}
 	defer cli.Close()
 
-	conf := cli.Config()
-	// find image
-	images, err := cli.FindImage(conf.Image)
+	options.tty = cli.UseTty(options.noTty)
+
+	options.container, err = cli.FindContainer(options.container)
+	if err != nil {
+		return err
+	}
+
+	options.env, err = cli.ResolveEnv(options)
 	if err != nil {
 		return err
 	}
-	if len(images) == 0 {
-		// pull image
-		err = cli.PullImage(conf.Image)
+
+	if err = cli.EnsureImage(options); err != nil {
+		return err
+	}
+
+	if options.clone {
+		cloneID, imageID, err := cli.CloneContainer(options.container, options)
 		if err != nil {
 			return err
 		}
+		if !options.keep {
+			defer cli.CloneClean(cloneID, imageID)
+		}
+		options.container = cloneID
 	}
 
-	containerID, err := cli.CreateContainer(options.container, options)
+	containerID, rootfs, err := cli.CreateContainer(options.container, options)
 	if err != nil {
 		return err
 	}
-	defer cli.ContainerClean(ctx, containerID)
+	options.rootfs = rootfs
+	if options.asTarget {
+		options, err = cli.TargetDefaults(options, containerID)
+		if err != nil {
+			return err
+		}
+	}
+	if len(options.command) == 0 {
+		options.command = cli.DetectShell(containerID)
+	}
+	if options.keep {
+		defer func() {
+			_, _ = fmt.Fprintf(
+				cli.Err(),
+				"debug container %s is kept, reattach with `docker-debug attach %s`\n",
+				containerID[:shortContainerIDSize],
+				options.container[:shortContainerIDSize],
+			)
+		}()
+	} else {
+		defer func() {
+			if err := cli.ContainerClean(ctx, containerID); err != nil {
+				_, _ = fmt.Fprintf(
+					cli.Err(),
+					"clean debug container %s failed: %s, remove it with `docker-debug gc`\n",
+					containerID[:shortContainerIDSize],
+					err,
+				)
+			}
+		}()
+	}
 
+	return runSession(ctx, cli, options, containerID)
+}
+
+// runSession exec command in debug container until it exits or target container stops
+func runSession(ctx context.Context, cli *DebugCli, options execOptions, containerID string) error {
 	resp, err := cli.ExecCreate(options, containerID)
 	if err != nil {
 		return err
 	}
 
-	errCh := make(chan error, 1)
-	defer close(errCh)
+	errCh := make(chan error, 2)
 
 	go func() {
 		errCh <- cli.ExecStart(options, resp.ID)
@@ -155,9 +334,22 @@ func runExec(options execOptions) error {
 	return <-errCh
 }
 
-// Execute main func
+// Execute main func, exits non-zero when the command fails
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		logrus.Debugf("%+v", err)
+	err := rootCmd.Execute()
+	if err == nil {
+		return
+	}
+	logrus.Debugf("%+v", err)
+	var status StatusError
+	if errors.As(err, &status) && err.Error() == status.Error() {
+		// the command already reported its failure, only pass its status on
+		if status.StatusCode > 0 {
+			os.Exit(status.StatusCode)
+		}
+		os.Exit(1)
 	}
+	_, _ = fmt.Fprintln(os.Stderr, "Error:", err)
+	commandCli.forgetStaleAPIVersion(err)
+	os.Exit(1)
 }
@@ -0,0 +1,46 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestCheckFlagVersions(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		apiVersion string
+		err        string
+	}{
+		{name: "unset flags are not checked", apiVersion: "1.30"},
+		{name: "new enough", args: []string{"--work-dir", "/app", "--platform", "linux/amd64"}, apiVersion: "1.41"},
+		{name: "work-dir too old", args: []string{"--work-dir", "/app"}, apiVersion: "1.30", err: "--work-dir requires docker api version 1.35, but the docker daemon api version is 1.30"},
+		{name: "platform too old", args: []string{"--platform", "linux/amd64"}, apiVersion: "1.40", err: "--platform requires docker api version 1.41"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := newExecOptions()
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			addExecFlags(flags, &options)
+			addImageFlags(flags, &options)
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			err := checkFlagVersions(flags, tt.apiVersion)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("checkFlagVersions() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("checkFlagVersions() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+	if err := checkFlagVersions(nil, "1.20"); err != nil {
+		t.Errorf("checkFlagVersions(nil) error = %v", err)
+	}
+}
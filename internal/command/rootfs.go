@@ -0,0 +1,139 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/pkg/errors"
+)
+
+// strategies to expose the target container rootfs at MountDir
+const (
+	// rootfsAuto try merged, pid, proc then none
+	rootfsAuto = "auto"
+	// rootfsMerged bind the storage driver MergedDir
+	rootfsMerged = "merged"
+	// rootfsPid bind /proc/<pid>/root of the target container on the docker host
+	rootfsPid = "pid"
+	// rootfsProc link MountDir to /proc/1/root of the shared pid namespace
+	rootfsProc = "proc"
+	// rootfsNone only network and pid namespaces are shared
+	rootfsNone = "none"
+)
+
+// rootfsCandidates returns the strategies to try in order
+func rootfsCandidates(strategy string, info types.ContainerJSON, mountDir string) ([]string, error) {
+	if mountDir == "" {
+		return []string{rootfsNone}, nil
+	}
+	mergedDir := info.GraphDriver.Data["MergedDir"]
+	switch strategy {
+	case "", rootfsAuto:
+		var candidates []string
+		if mergedDir != "" {
+			candidates = append(candidates, rootfsMerged)
+		}
+		if info.State.Pid > 0 {
+			candidates = append(candidates, rootfsPid)
+		}
+		return append(candidates, rootfsProc, rootfsNone), nil
+	case rootfsMerged:
+		if mergedDir == "" {
+			return nil, errors.Errorf("container: `%s` not found merged dir, storage driver is %s", info.ID, info.Driver)
+		}
+	case rootfsPid:
+		if info.State.Pid <= 0 {
+			return nil, errors.Errorf("container: `%s` has no pid", info.ID)
+		}
+	case rootfsProc, rootfsNone:
+	default:
+		return nil, errors.Errorf("unknown rootfs strategy: `%s` (auto|merged|pid|proc|none)", strategy)
+	}
+	return []string{strategy}, nil
+}
+
+// rootfsSource returns host path of the target rootfs, empty when the strategy not binds it
+func rootfsSource(strategy string, info types.ContainerJSON) string {
+	switch strategy {
+	case rootfsMerged:
+		return info.GraphDriver.Data["MergedDir"]
+	case rootfsPid:
+		return fmt.Sprintf("/proc/%d/root", info.State.Pid)
+	}
+	return ""
+}
+
+// rootfsMounts bind the target rootfs and its volumes under mountDir
+func rootfsMounts(strategy string, info types.ContainerJSON, mountDir string) []mount.Mount {
+	source := rootfsSource(strategy, info)
+	if source == "" {
+		return nil
+	}
+	mounts := []mount.Mount{{
+		Type:   "bind",
+		Source: source,
+		Target: mountDir,
+	}}
+	if strategy != rootfsMerged {
+		// volumes are already visible in the mount namespace of the target
+		return mounts
+	}
+	for _, i := range info.Mounts {
+		var mountType = i.Type
+		if i.Type == "volume" {
+			mountType = "bind"
+		}
+		mounts = append(mounts, mount.Mount{
+			Type:     mountType,
+			Source:   i.Source,
+			Target:   mountDir + i.Destination,
+			ReadOnly: !i.RW,
+		})
+	}
+	return mounts
+}
+
+// linkProcRoot link mountDir to the root of pid 1 in the shared pid namespace
+func (cli *DebugCli) linkProcRoot(containerID string) error {
+	_, err := cli.execOutput(containerID, []string{
+		"sh", "-c",
+		`ls /proc/1/root/ >/dev/null && mkdir -p "$(dirname "$1")" && { rmdir "$1" 2>/dev/null; ln -sn /proc/1/root "$1"; }`,
+		"sh", cli.config.MountDir,
+	})
+	return err
+}
+
+// execOutput run a command in container without tty and returns its stdout
+func (cli *DebugCli) execOutput(containerID string, cmd []string) (string, error) {
+	ctx, cancel := cli.withContent(cli.config.Timeout)
+	defer cancel()
+	resp, err := cli.client.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          cmd,
+	})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	attach, err := cli.client.ContainerExecAttach(ctx, resp.ID, container.ExecStartOptions{})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer attach.Close()
+	var stdout, stderr bytes.Buffer
+	if _, err = stdcopy.StdCopy(&stdout, &stderr, attach.Reader); err != nil {
+		return "", errors.WithStack(err)
+	}
+	if err = getExecExitStatus(ctx, cli.client, resp.ID); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.Wrap(err, msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
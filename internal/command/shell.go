@@ -0,0 +1,81 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/docker/docker/api/types/strslice"
+)
+
+// defaultEntrypoints keep-alive entrypoints tried in order until one starts
+var defaultEntrypoints = []strslice.StrSlice{
+	{"/usr/bin/env", "sh"},
+	{"/bin/sh"},
+	{"/bin/bash"},
+	{"/bin/busybox", "sh"},
+}
+
+// interactiveShells shells to detect when COMMAND is omitted, best first
+var interactiveShells = []string{"bash", "zsh", "ash", "sh"}
+
+// entrypoints returns the keep-alive entrypoints of the debug container, from
+// flags or profile, then the image entrypoint in config, then the defaults
+func (cli *DebugCli) entrypoints(options execOptions) []strslice.StrSlice {
+	if len(options.entrypoint) > 0 {
+		return []strslice.StrSlice{options.entrypoint}
+	}
+	if entrypoint := cli.config.ImageEntrypoint(cli.config.Image); len(entrypoint) > 0 {
+		return []strslice.StrSlice{entrypoint}
+	}
+	return defaultEntrypoints
+}
+
+// isExecNotFound reports whether the container failed to start because the entrypoint is missing
+func isExecNotFound(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "executable file not found") ||
+		strings.Contains(msg, "no such file or directory")
+}
+
+// DetectShell returns the best interactive shell of the debug container,
+// falls back to its keep-alive entrypoint
+func (cli *DebugCli) DetectShell(containerID string) []string {
+	script := "for s in " + strings.Join(interactiveShells, " ") + "; do command -v $s && exit 0; done; exit 1"
+	out, err := cli.execOutput(containerID, []string{"sh", "-c", script})
+	if err == nil {
+		if shell, _, _ := strings.Cut(strings.TrimSpace(out), "\n"); shell != "" {
+			return []string{shell}
+		}
+	}
+	ctx, cancel := cli.withContent(cli.config.Timeout)
+	defer cancel()
+	info, err := cli.client.ContainerInspect(ctx, containerID)
+	if err == nil && len(info.Config.Entrypoint) > 0 {
+		return info.Config.Entrypoint
+	}
+	return []string{"sh"}
+}
+
+// entrypointValue parse --entrypoint "/bin/sh -l" to a command slice
+type entrypointValue struct {
+	value *[]string
+}
+
+func newEntrypointValue(p *[]string) *entrypointValue {
+	return &entrypointValue{value: p}
+}
+
+func (e *entrypointValue) String() string {
+	if e.value == nil {
+		return ""
+	}
+	return strings.Join(*e.value, " ")
+}
+
+func (e *entrypointValue) Set(s string) error {
+	*e.value = strings.Fields(s)
+	return nil
+}
+
+func (e *entrypointValue) Type() string {
+	return "string"
+}
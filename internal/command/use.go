@@ -12,7 +12,7 @@ func init() {
 		Short: "docker set default config",
 		Args:  RequiresMinArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			conf, err := config.LoadConfig()
+			conf, err := config.LoadFileConfig()
 			if err != nil {
 				return err
 			}
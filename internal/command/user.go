@@ -0,0 +1,105 @@
+package command
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// TargetDefaults default the user and working directory of the exec from the
+// target container, user names are resolved with the target /etc/passwd and /etc/group
+func (cli *DebugCli) TargetDefaults(options execOptions, sidecarID string) (execOptions, error) {
+	ctx, cancel := cli.withContent(cli.config.Timeout)
+	info, err := cli.client.ContainerInspect(ctx, options.container)
+	cancel()
+	if err != nil {
+		return options, errors.WithStack(err)
+	}
+	if options.workDir == "" && options.targetDir == "" {
+		options.targetDir = info.Config.WorkingDir
+	}
+	if options.user == "" && info.Config.User != "" {
+		options.user, err = cli.resolveTargetUser(sidecarID, info.Config.User, options.rootfs)
+		if err != nil {
+			return options, err
+		}
+	}
+	return options, nil
+}
+
+// resolveTargetUser convert user[:group] to numeric uid[:gid] of the target container
+func (cli *DebugCli) resolveTargetUser(sidecarID, spec, rootfs string) (string, error) {
+	name, group, hasGroup := strings.Cut(spec, ":")
+	if rootfs == rootfsNone || cli.config.MountDir == "" {
+		if isNumeric(name) && (!hasGroup || isNumeric(group)) {
+			return spec, nil
+		}
+		return "", errors.Errorf("user `%s` of the target container can not be resolved without its rootfs", spec)
+	}
+	passwd, err := cli.readTargetFile(sidecarID, "/etc/passwd")
+	if err != nil {
+		logrus.Debugf("%+v", err)
+	}
+	uid, gid := name, ""
+	for _, fields := range passwd {
+		if len(fields) < 4 {
+			continue
+		}
+		if fields[0] == name || fields[2] == name {
+			uid, gid = fields[2], fields[3]
+			break
+		}
+	}
+	if !isNumeric(uid) {
+		return "", errors.Errorf("user `%s` not found in the target /etc/passwd", name)
+	}
+	if hasGroup {
+		gid = group
+		if !isNumeric(group) {
+			groups, err := cli.readTargetFile(sidecarID, "/etc/group")
+			if err != nil {
+				return "", err
+			}
+			gid = ""
+			for _, fields := range groups {
+				if len(fields) >= 3 && fields[0] == group {
+					gid = fields[2]
+					break
+				}
+			}
+			if gid == "" {
+				return "", errors.Errorf("group `%s` not found in the target /etc/group", group)
+			}
+		}
+	}
+	if gid == "" {
+		return uid, nil
+	}
+	return fmt.Sprintf("%s:%s", uid, gid), nil
+}
+
+// readTargetFile read a colon separated file of the target rootfs at MountDir
+func (cli *DebugCli) readTargetFile(sidecarID, file string) ([][]string, error) {
+	out, err := cli.execOutput(sidecarID, []string{"cat", path.Join(cli.config.MountDir, file)})
+	if err != nil {
+		return nil, err
+	}
+	var lines [][]string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, strings.Split(line, ":"))
+	}
+	return lines, nil
+}
+
+func isNumeric(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 32)
+	return err == nil
+}
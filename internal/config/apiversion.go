@@ -0,0 +1,75 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// AutoVersion negotiate the api version with the daemon, like an empty version
+	AutoVersion = "auto"
+
+	apiVersionCacheName = "api_versions.json"
+	apiVersionCacheTTL  = time.Hour * 24
+)
+
+type apiVersionEntry struct {
+	Version    string    `json:"version"`
+	Negotiated time.Time `json:"negotiated"`
+}
+
+// IsAutoVersion report whether the docker config negotiates its api version
+func IsAutoVersion(version string) bool {
+	return version == "" || version == AutoVersion
+}
+
+func apiVersionCacheFile() string {
+	return filepath.Join(filepath.Dir(File), apiVersionCacheName)
+}
+
+func readAPIVersionCache() map[string]apiVersionEntry {
+	cache := map[string]apiVersionEntry{}
+	data, err := os.ReadFile(apiVersionCacheFile())
+	if err == nil {
+		_ = json.Unmarshal(data, &cache)
+	}
+	return cache
+}
+
+// CachedAPIVersion returns the api version negotiated with host in the last day, empty when none
+func CachedAPIVersion(host string) string {
+	entry, ok := readAPIVersionCache()[host]
+	if !ok || time.Since(entry.Negotiated) > apiVersionCacheTTL {
+		return ""
+	}
+	return entry.Version
+}
+
+// CacheAPIVersion save the api version negotiated with host
+func CacheAPIVersion(host, version string) error {
+	cache := readAPIVersionCache()
+	cache[host] = apiVersionEntry{Version: version, Negotiated: time.Now()}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.WriteFile(apiVersionCacheFile(), data, 0644))
+}
+
+// ForgetAPIVersion drop the cached api version of host
+func ForgetAPIVersion(host string) error {
+	cache := readAPIVersionCache()
+	if _, ok := cache[host]; !ok {
+		return nil
+	}
+	delete(cache, host)
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.WriteFile(apiVersionCacheFile(), data, 0644))
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAPIVersionCache(t *testing.T) {
+	old := File
+	File = filepath.Join(t.TempDir(), "config.toml")
+	t.Cleanup(func() { File = old })
+
+	const host = "unix:///var/run/docker.sock"
+	if v := CachedAPIVersion(host); v != "" {
+		t.Fatalf("CachedAPIVersion() = %q on an empty cache", v)
+	}
+	if err := CacheAPIVersion(host, "1.43"); err != nil {
+		t.Fatal(err)
+	}
+	if err := CacheAPIVersion("tcp://other:2376", "1.41"); err != nil {
+		t.Fatal(err)
+	}
+	if v := CachedAPIVersion(host); v != "1.43" {
+		t.Fatalf("CachedAPIVersion() = %q, want 1.43", v)
+	}
+	if err := ForgetAPIVersion(host); err != nil {
+		t.Fatal(err)
+	}
+	if v := CachedAPIVersion(host); v != "" {
+		t.Errorf("CachedAPIVersion() = %q after ForgetAPIVersion", v)
+	}
+	if v := CachedAPIVersion("tcp://other:2376"); v != "1.41" {
+		t.Errorf("ForgetAPIVersion dropped another host, got %q", v)
+	}
+	if err := ForgetAPIVersion("tcp://unknown:2376"); err != nil {
+		t.Errorf("ForgetAPIVersion() of an unknown host error = %v", err)
+	}
+}
@@ -4,10 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/BurntSushi/toml"
-	"github.com/docker/docker/api"
 	"github.com/pkg/errors"
 	"github.com/zeromake/docker-debug/pkg/opts"
 	"github.com/zeromake/docker-debug/version"
@@ -41,15 +42,19 @@ func init() {
 	//HOME = home
 	configDir = fmt.Sprintf("%s%s%s", home, PathSeparator, configDir)
 	File = fmt.Sprintf("%s%s%s", configDir, PathSeparator, configName)
+	File = defaultFile(File)
 }
 
 // DockerConfig docker 配置
 type DockerConfig struct {
-	Version      string `toml:"version"`
-	Host         string `toml:"host"`
-	TLS          bool   `toml:"tls"`
-	CertDir      string `toml:"cert_dir"`
-	CertPassword string `toml:"cert_password"`
+	Version      string `toml:"version" json:"version"`
+	Host         string `toml:"host" json:"host"`
+	TLS          bool   `toml:"tls" json:"tls"`
+	CertDir      string `toml:"cert_dir" json:"cert_dir"`
+	CertPassword string `toml:"cert_password" json:"cert_password"`
+
+	// forcedVersion the version was set by the 0.7.10 migration, not by the user
+	forcedVersion bool
 }
 
 func (c DockerConfig) String() string {
@@ -57,6 +62,45 @@ func (c DockerConfig) String() string {
 	return string(s)
 }
 
+// Profile named debug options, selected with --profile
+type Profile struct {
+	Image        string   `toml:"image,omitempty"`
+	Config       string   `toml:"config,omitempty"`
+	Host         string   `toml:"host,omitempty"`
+	Context      string   `toml:"context,omitempty"`
+	CertDir      string   `toml:"cert_dir,omitempty"`
+	DetachKeys   string   `toml:"detach_keys,omitempty"`
+	User         string   `toml:"user,omitempty"`
+	Privileged   bool     `toml:"privileged,omitempty"`
+	WorkDir      string   `toml:"work_dir,omitempty"`
+	TargetDir    string   `toml:"target_dir,omitempty"`
+	Volumes      []string `toml:"volumes,omitempty"`
+	IPC          bool     `toml:"ipc,omitempty"`
+	SecurityOpts []string `toml:"security_opts,omitempty"`
+	CapAdds      []string `toml:"cap_adds,omitempty"`
+	NoTTY        bool     `toml:"no_tty,omitempty"`
+	Record       string   `toml:"record,omitempty"`
+	Keep         bool     `toml:"keep,omitempty"`
+	Clone        bool     `toml:"clone,omitempty"`
+	Rootfs       string   `toml:"rootfs,omitempty"`
+	Env          []string `toml:"env,omitempty"`
+	EnvFiles     []string `toml:"env_files,omitempty"`
+	InheritEnv   bool     `toml:"inherit_env,omitempty"`
+	EnvDeny      []string `toml:"env_deny,omitempty"`
+	AsTarget     bool     `toml:"as_target,omitempty"`
+	Entrypoint   []string `toml:"entrypoint,omitempty"`
+	Pull         string   `toml:"pull,omitempty"`
+	Platform     string   `toml:"platform,omitempty"`
+	ImageArchive string   `toml:"image_archive,omitempty"`
+}
+
+// RegistryAuth credentials of a registry, preferred over ~/.docker/config.json
+type RegistryAuth struct {
+	Username      string `toml:"username,omitempty"`
+	Password      string `toml:"password,omitempty"`
+	IdentityToken string `toml:"identity_token,omitempty"`
+}
+
 // Config 配置
 type Config struct {
 	Version             string                   `toml:"version"`
@@ -66,6 +110,24 @@ type Config struct {
 	DockerConfigDefault string                   `toml:"config_default"`
 	DockerConfig        map[string]*DockerConfig `toml:"config"`
 	ReadTimeout         time.Duration            `toml:"read_timeout"`
+	GCMaxAge            time.Duration            `toml:"gc_max_age"`
+	Profiles            map[string]*Profile      `toml:"profile,omitempty"`
+	Entrypoints         map[string][]string      `toml:"entrypoints,omitempty"`
+	EnvDeny             []string                 `toml:"env_deny,omitempty"`
+	Registries          map[string]*RegistryAuth `toml:"registry,omitempty"`
+	Pull                string                   `toml:"pull,omitempty"`
+}
+
+// ImageEntrypoint returns the keep-alive entrypoint configured for the image,
+// matched by the full reference then without tag
+func (c *Config) ImageEntrypoint(image string) []string {
+	if entrypoint, ok := c.Entrypoints[image]; ok {
+		return entrypoint
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return c.Entrypoints[image[:i]]
+	}
+	return nil
 }
 
 // Save to default file
@@ -99,43 +161,62 @@ func PathExists(path string) bool {
 	return false
 }
 
-// LoadConfig load default file(not has init file)
-func LoadConfig() (*Config, error) {
+// LoadFileConfig load default file(not has init file), without project file and env overrides,
+// use it to edit and Save the config
+func LoadFileConfig() (*Config, error) {
 	if !PathExists(File) {
 		return InitConfig()
 	}
-	config := &Config{}
-	_, err := toml.DecodeFile(File, config)
+	config, _, err := ReadFile()
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, err
 	}
 	err = MigrationConfig(config)
 	return config, err
 }
 
-// InitConfig init create file
-func InitConfig() (*Config, error) {
+// ReadFile decode the config file as is, without migration
+func ReadFile() (*Config, toml.MetaData, error) {
+	config := &Config{}
+	md, err := toml.DecodeFile(File, config)
+	if err != nil {
+		return nil, md, errors.Wrapf(err, "parse %s", File)
+	}
+	return config, md, nil
+}
+
+// EnvDockerConfig docker config of DOCKER_HOST, DOCKER_TLS_VERIFY and DOCKER_CERT_PATH
+func EnvDockerConfig() (*DockerConfig, error) {
 	host := os.Getenv("DOCKER_HOST")
 	tlsVerify := os.Getenv("DOCKER_TLS_VERIFY") == "1"
 	host, err := opts.ParseHost(tlsVerify, host)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	if !PathExists(configDir) {
-		err = os.Mkdir(configDir, 0755)
-		if err != nil {
-			return nil, errors.WithStack(err)
-		}
-	}
-	dc := DockerConfig{
+	dc := &DockerConfig{
 		Host:    host,
-		Version: api.DefaultVersion,
+		Version: AutoVersion,
 	}
 	certPath := os.Getenv("DOCKER_CERT_PATH")
 	if tlsVerify && certPath != "" {
 		dc.TLS = true
 		dc.CertDir = certPath
 	}
+	return dc, nil
+}
+
+// InitConfig init create file
+func InitConfig() (*Config, error) {
+	dc, err := EnvDockerConfig()
+	if err != nil {
+		return nil, err
+	}
+	if dir := filepath.Dir(File); !PathExists(dir) {
+		err = os.MkdirAll(dir, 0755)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
 	config := &Config{
 		Version:             version.Version,
 		Image:               "nicolaka/netshoot:latest",
@@ -143,9 +224,10 @@ func InitConfig() (*Config, error) {
 		MountDir:            "/mnt/container",
 		DockerConfigDefault: "default",
 		DockerConfig: map[string]*DockerConfig{
-			"default": &dc,
+			"default": dc,
 		},
 		ReadTimeout: time.Second * 3,
+		GCMaxAge:    time.Hour * 24,
 	}
 	file, err := os.OpenFile(File, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
 	if err != nil {
@@ -0,0 +1,304 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+)
+
+const (
+	// EnvConfigFile env of the config file path
+	EnvConfigFile = "DOCKER_DEBUG_CONFIG"
+	// EnvPrefix prefix of DOCKER_DEBUG_<FIELD> overrides
+	EnvPrefix = "DOCKER_DEBUG_"
+	// ProjectFileName project-local config merged over the user config
+	ProjectFileName = ".docker-debug.toml"
+	// SourceDefault source of values not set by any layer
+	SourceDefault = "default"
+
+	xdgDirName = "docker-debug"
+)
+
+// defaultFile returns $DOCKER_DEBUG_CONFIG, then $XDG_CONFIG_HOME/docker-debug/config.toml
+// unless only the legacy file exists
+func defaultFile(legacy string) string {
+	if file := os.Getenv(EnvConfigFile); file != "" {
+		return file
+	}
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" {
+		return legacy
+	}
+	file := filepath.Join(xdg, xdgDirName, configName)
+	if PathExists(file) || !PathExists(legacy) {
+		return file
+	}
+	return legacy
+}
+
+// SetFile use the config file of the --config flag
+func SetFile(file string) {
+	File = file
+}
+
+// Sources where each config key comes from, keyed by dotted toml key
+type Sources map[string]string
+
+// Lookup returns the source of the key or of its closest parent table
+func (s Sources) Lookup(key string) string {
+	for {
+		if source, ok := s[key]; ok {
+			return source
+		}
+		i := strings.LastIndex(key, ".")
+		if i == -1 {
+			return SourceDefault
+		}
+		key = key[:i]
+	}
+}
+
+// FindProjectFile walk up from dir to find .docker-debug.toml
+func FindProjectFile(dir string) string {
+	for {
+		file := filepath.Join(dir, ProjectFileName)
+		if PathExists(file) {
+			return file
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// LoadConfig load the user config merged with the project file and env overrides
+func LoadConfig() (*Config, error) {
+	conf, _, err := LoadMergedConfig()
+	return conf, err
+}
+
+// LoadMergedConfig load the user config, then the project file (only projectKeys),
+// then DOCKER_DEBUG_<FIELD> overrides
+func LoadMergedConfig() (*Config, Sources, error) {
+	sources := Sources{}
+	conf, err := LoadFileConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	if md, err := toml.DecodeFile(File, &Config{}); err == nil {
+		addSources(sources, md, File)
+	}
+
+	if cwd, err := os.Getwd(); err == nil {
+		if project := FindProjectFile(cwd); project != "" && !sameFile(project, File) {
+			if err = mergeProjectFile(conf, sources, project); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if err = applyEnv(conf, sources); err != nil {
+		return nil, nil, err
+	}
+	return conf, sources, nil
+}
+
+func addSources(sources Sources, md toml.MetaData, source string) {
+	for _, key := range md.Keys() {
+		sources[key.String()] = source
+	}
+}
+
+// projectKeys keys a project file may set, a cloned repository must not choose
+// the docker host, the debug image, its entrypoint or the registry credentials
+var projectKeys = map[string]func(dst, src *Config){
+	"mount_dir":    func(dst, src *Config) { dst.MountDir = src.MountDir },
+	"timeout":      func(dst, src *Config) { dst.Timeout = src.Timeout },
+	"read_timeout": func(dst, src *Config) { dst.ReadTimeout = src.ReadTimeout },
+	"gc_max_age":   func(dst, src *Config) { dst.GCMaxAge = src.GCMaxAge },
+	"pull":         func(dst, src *Config) { dst.Pull = src.Pull },
+	"env_deny":     func(dst, src *Config) { dst.EnvDeny = append(dst.EnvDeny, src.EnvDeny...) },
+}
+
+// mergeProjectFile merge the projectKeys of the project file, other keys are ignored with a warning
+func mergeProjectFile(conf *Config, sources Sources, file string) error {
+	project := &Config{}
+	md, err := toml.DecodeFile(file, project)
+	if err != nil {
+		return errors.Wrapf(err, "parse %s", file)
+	}
+	var ignored []string
+	seen := map[string]bool{}
+	for _, key := range md.Keys() {
+		name := key[0]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		apply, ok := projectKeys[name]
+		if !ok {
+			ignored = append(ignored, name)
+			continue
+		}
+		apply(conf, project)
+		sources[name] = file
+	}
+	if len(ignored) > 0 {
+		_, _ = fmt.Fprintf(
+			os.Stderr,
+			"WARNING: %s: ignored %s, a project file can only set %s\n",
+			file,
+			strings.Join(ignored, ", "),
+			strings.Join(sortedProjectKeys(), ", "),
+		)
+	}
+	return nil
+}
+
+func sortedProjectKeys() []string {
+	keys := make([]string, 0, len(projectKeys))
+	for k := range projectKeys {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sameFile(a, b string) bool {
+	ia, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	ib, err := os.Stat(b)
+	return err == nil && os.SameFile(ia, ib)
+}
+
+// applyEnv apply DOCKER_DEBUG_<FIELD> to scalar and list fields, lists are comma separated
+func applyEnv(conf *Config, sources Sources) error {
+	v := reflect.ValueOf(conf).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := tomlKey(t.Field(i))
+		name := EnvPrefix + strings.ToUpper(key)
+		value, ok := os.LookupEnv(name)
+		if !ok || name == EnvConfigFile {
+			continue
+		}
+		field := v.Field(i)
+		switch {
+		case field.Type() == reflect.TypeOf(time.Duration(0)):
+			d, err := parseDuration(value)
+			if err != nil {
+				return errors.Errorf("%s: invalid duration `%s`", name, value)
+			}
+			field.SetInt(int64(d))
+		case field.Kind() == reflect.String:
+			field.SetString(value)
+		case field.Kind() == reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return errors.Errorf("%s: invalid bool `%s`", name, value)
+			}
+			field.SetBool(b)
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+			var list []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			field.Set(reflect.ValueOf(list))
+		default:
+			continue
+		}
+		sources[key] = "env " + name
+	}
+	return nil
+}
+
+// parseDuration accept a go duration or nanoseconds like the toml file
+func parseDuration(value string) (time.Duration, error) {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(n), nil
+	}
+	return time.ParseDuration(value)
+}
+
+func tomlKey(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// quoteKey quote a map key which is not a bare toml key, like toml.Key.String
+func quoteKey(k string) string {
+	for _, r := range k {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return strconv.Quote(k)
+		}
+	}
+	return k
+}
+
+// Entry a flattened config value
+type Entry struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// Flatten list the config values by dotted toml key with their source
+func Flatten(conf *Config, sources Sources) []Entry {
+	var entries []Entry
+	flatten(reflect.ValueOf(conf).Elem(), "", func(key, value string) {
+		entries = append(entries, Entry{Key: key, Value: value, Source: sources.Lookup(key)})
+	})
+	return entries
+}
+
+func flatten(v reflect.Value, prefix string, fn func(key, value string)) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch {
+	case v.Kind() == reflect.Ptr:
+		if !v.IsNil() {
+			flatten(v.Elem(), prefix, fn)
+		}
+	case v.Kind() == reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			flatten(v.Field(i), join(tomlKey(t.Field(i))), fn)
+		}
+	case v.Kind() == reflect.Map:
+		keys := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			flatten(v.MapIndex(reflect.ValueOf(k)), join(quoteKey(k)), fn)
+		}
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		fn(prefix, time.Duration(v.Int()).String())
+	default:
+		fn(prefix, fmt.Sprint(v.Interface()))
+	}
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadMergedConfig(t *testing.T) {
+	withVersion(t, "unknown-version")
+	dir := t.TempDir()
+	old := File
+	File = filepath.Join(dir, "home", "config.toml")
+	t.Cleanup(func() { File = old })
+	if err := os.MkdirAll(filepath.Dir(File), 0755); err != nil {
+		t.Fatal(err)
+	}
+	conf := validConfig("0.8.0")
+	conf.EnvDeny = []string{"*TOKEN*"}
+	if err := conf.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	project := filepath.Join(dir, "repo")
+	sub := filepath.Join(project, "sub")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	err := os.WriteFile(filepath.Join(project, ProjectFileName), []byte(`
+image = "evil/image"
+timeout = "20s"
+env_deny = ["*SECRET*"]
+config_default = "evil"
+[config.evil]
+  host = "tcp://evil:2375"
+[config.default]
+  host = "tcp://evil:2375"
+[registry."docker.io"]
+  password = "x"
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(sub); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(cwd) })
+	t.Setenv(EnvPrefix+"READ_TIMEOUT", "7s")
+	t.Setenv(EnvPrefix+"MOUNT_DIR", "/mnt/env")
+
+	merged, sources, err := LoadMergedConfig()
+	if err != nil {
+		t.Fatalf("LoadMergedConfig() error = %+v", err)
+	}
+	projectFile := filepath.Join(project, ProjectFileName)
+	tests := []struct {
+		key, source string
+		ok          bool
+	}{
+		{"image", File, merged.Image == "nicolaka/netshoot:latest"},
+		{"config_default", File, merged.DockerConfigDefault == "default"},
+		{"config.default.host", File, merged.DockerConfig["default"].Host == "unix:///var/run/docker.sock"},
+		{"timeout", projectFile, merged.Timeout == time.Second*20},
+		{"env_deny", projectFile, len(merged.EnvDeny) == 2},
+		{"read_timeout", "env " + EnvPrefix + "READ_TIMEOUT", merged.ReadTimeout == time.Second*7},
+		{"mount_dir", "env " + EnvPrefix + "MOUNT_DIR", merged.MountDir == "/mnt/env"},
+	}
+	for _, tt := range tests {
+		if !tt.ok {
+			t.Errorf("%s has a wrong value", tt.key)
+		}
+		if got := sources.Lookup(tt.key); got != tt.source {
+			t.Errorf("source of %s = %s, want %s", tt.key, got, tt.source)
+		}
+	}
+	if _, ok := merged.DockerConfig["evil"]; ok {
+		t.Error("project file added a docker config")
+	}
+	if len(merged.Registries) != 0 {
+		t.Error("project file added registry credentials")
+	}
+}
@@ -1,11 +1,16 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
 	"github.com/blang/semver"
 	"github.com/pkg/errors"
 	"github.com/zeromake/docker-debug/version"
-	"sort"
-	"strings"
 )
 
 type migration struct {
@@ -15,47 +20,158 @@ type migration struct {
 
 var migrationArr []*migration
 
-// MigrationConfig migration config version
-func MigrationConfig(conf *Config) error {
-	ver1 := version.Version
-	var flag bool
-	if strings.HasPrefix(ver1, "v") {
-		ver1 = ver1[1:]
+// MigrationStep a migration with the config values it changes
+type MigrationStep struct {
+	Name string
+	Diff []string
+}
+
+// parseVersion parse a semver with optional v prefix,
+// false for dev builds (unknown-version) and hand written versions
+func parseVersion(s string) (semver.Version, bool) {
+	v, err := semver.Parse(strings.TrimPrefix(s, "v"))
+	return v, err == nil
+}
+
+// PlanMigration apply the pending migrations to a copy of conf,
+// returns the migrated copy and what each step changes, nil when nothing changes
+func PlanMigration(conf *Config) (*Config, []MigrationStep, error) {
+	binVersion, ok := parseVersion(version.Version)
+	if !ok {
+		// dev build, the config schema is the current one
+		return nil, nil, nil
+	}
+	var pending []*migration
+	confVersion, ok := parseVersion(conf.Version)
+	switch {
+	case !ok:
+		// not written by a release, assume the current schema
+	case confVersion.GT(binVersion):
+		return nil, nil, errors.Errorf(
+			"config %s was written by docker-debug %s which is newer than %s, upgrade docker-debug or use another --config",
+			File,
+			conf.Version,
+			version.Version,
+		)
+	default:
+		sort.Slice(migrationArr, func(i, j int) bool {
+			return migrationArr[i].Version.LT(migrationArr[j].Version)
+		})
+		for _, m := range migrationArr {
+			if confVersion.LT(m.Version) {
+				pending = append(pending, m)
+			}
+		}
 	}
-	v1, err := semver.Parse(ver1)
+
+	migrated, err := cloneConfig(conf)
 	if err != nil {
+		return nil, nil, err
+	}
+	var steps []MigrationStep
+	step := func(name string, up func(*Config) error) error {
+		before := RedactEntries(Flatten(migrated, nil))
+		if err := up(migrated); err != nil {
+			return errors.Wrapf(err, "migration %s", name)
+		}
+		if diff := diffEntries(before, RedactEntries(Flatten(migrated, nil))); len(diff) > 0 {
+			steps = append(steps, MigrationStep{Name: name, Diff: diff})
+		}
 		return nil
 	}
-	ver2 := conf.Version
-	if strings.HasPrefix(ver2, "v") {
-		ver2 = ver2[1:]
+	for _, m := range pending {
+		if err = step(m.Version.String(), m.Up); err != nil {
+			return nil, nil, err
+		}
 	}
-	v2, err := semver.Parse(ver2)
+	err = step("mount_dir", func(c *Config) error {
+		c.MountDir = strings.TrimSuffix(c.MountDir, "/")
+		return nil
+	})
 	if err != nil {
-		return errors.WithStack(err)
+		return nil, nil, err
 	}
-	if strings.HasSuffix(conf.MountDir, "/") {
-		flag = true
-		l := len(conf.MountDir)
-		conf.MountDir = conf.MountDir[:l-1]
+	if len(steps) == 0 {
+		return nil, nil, nil
 	}
-	if v2.LT(v1) {
-		sort.Slice(migrationArr, func(i, j int) bool {
-			return migrationArr[i].Version.LT(migrationArr[j].Version)
-		})
-		for _, m := range migrationArr {
-			if v2.LT(m.Version) {
-				err = m.Up(conf)
-				if err != nil {
-					return err
-				}
-			}
-		}
-		conf.Version = ver1
-		return conf.Save()
+	err = step("version", func(c *Config) error {
+		c.Version = strings.TrimPrefix(version.Version, "v")
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return migrated, steps, nil
+}
+
+// MigrationConfig migration config version, the old file is kept as a backup
+// and the migrated config is validated before it is saved
+func MigrationConfig(conf *Config) error {
+	migrated, steps, err := PlanMigration(conf)
+	if err != nil || migrated == nil {
+		return err
 	}
-	if flag {
-		return conf.Save()
+	if err = migrated.Validate(); err != nil {
+		return errors.Wrapf(err, "migrated config is invalid, %s is unchanged (see `docker-debug config migrate --dry-run`)", File)
 	}
+	backup, err := BackupFile()
+	if err != nil {
+		return err
+	}
+	*conf = *migrated
+	if err = conf.Save(); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(steps))
+	for _, s := range steps {
+		names = append(names, s.Name)
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "config migrated (%s), backup: %s\n", strings.Join(names, ", "), backup)
 	return nil
 }
+
+// BackupFile copy the config file next to it with a timestamp suffix
+func BackupFile() (string, error) {
+	data, err := os.ReadFile(File)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	backup := fmt.Sprintf("%s.%s.bak", File, time.Now().Format("20060102150405"))
+	return backup, errors.WithStack(os.WriteFile(backup, data, 0600))
+}
+
+func cloneConfig(conf *Config) (*Config, error) {
+	data, err := json.Marshal(conf)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	c := &Config{}
+	return c, errors.WithStack(json.Unmarshal(data, c))
+}
+
+// diffEntries returns `- key = old` and `+ key = new` lines of the changed values
+func diffEntries(before, after []Entry) []string {
+	values := map[string]string{}
+	for _, e := range before {
+		values[e.Key] = e.Value
+	}
+	seen := map[string]bool{}
+	var diff []string
+	for _, e := range after {
+		seen[e.Key] = true
+		old, ok := values[e.Key]
+		if ok && old == e.Value {
+			continue
+		}
+		if ok {
+			diff = append(diff, fmt.Sprintf("- %s = %s", e.Key, old))
+		}
+		diff = append(diff, fmt.Sprintf("+ %s = %s", e.Key, e.Value))
+	}
+	for _, e := range before {
+		if !seen[e.Key] {
+			diff = append(diff, fmt.Sprintf("- %s = %s", e.Key, e.Value))
+		}
+	}
+	return diff
+}
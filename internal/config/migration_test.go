@@ -0,0 +1,241 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zeromake/docker-debug/version"
+)
+
+func withVersion(t *testing.T, v string) {
+	t.Helper()
+	old := version.Version
+	version.Version = v
+	t.Cleanup(func() { version.Version = old })
+}
+
+func validConfig(v string) *Config {
+	return &Config{
+		Version:             v,
+		Image:               "nicolaka/netshoot:latest",
+		MountDir:            "/mnt/container",
+		Timeout:             time.Second * 10,
+		ReadTimeout:         time.Second * 3,
+		DockerConfigDefault: "default",
+		DockerConfig: map[string]*DockerConfig{
+			"default": {Host: "unix:///var/run/docker.sock", Version: AutoVersion},
+		},
+	}
+}
+
+func TestPlanMigration(t *testing.T) {
+	tests := []struct {
+		name       string
+		binVersion string
+		conf       func() *Config
+		err        string
+		// steps names of the planned migrations, nil when nothing changes
+		steps []string
+		// versions of the docker configs after the migration
+		versions map[string]string
+	}{
+		{
+			name:       "dev build skips migration",
+			binVersion: "unknown-version",
+			conf:       func() *Config { return validConfig("0.7.0") },
+		},
+		{
+			name:       "up to date",
+			binVersion: "0.8.0",
+			conf:       func() *Config { return validConfig("0.8.0") },
+		},
+		{
+			name:       "newer config is refused",
+			binVersion: "0.8.0",
+			conf:       func() *Config { return validConfig("0.9.0") },
+			err:        "newer than 0.8.0",
+		},
+		{
+			name:       "hand written version is the current schema",
+			binVersion: "0.8.0",
+			conf: func() *Config {
+				c := validConfig("latest")
+				c.MountDir = "/mnt/container/"
+				return c
+			},
+			steps: []string{"mount_dir", "version"},
+		},
+		{
+			name:       "forced versions negotiate, pinned versions are kept",
+			binVersion: "v0.8.0",
+			conf: func() *Config {
+				c := validConfig("0.7.0")
+				c.DockerConfig["default"].Version = ""
+				c.DockerConfig["pinned"] = &DockerConfig{Host: "unix:///pinned.sock", Version: "1.40"}
+				return c
+			},
+			steps:    []string{"0.7.2", "0.7.10", "0.8.0", "version"},
+			versions: map[string]string{"default": AutoVersion, "pinned": "1.40"},
+		},
+		{
+			name:       "pinned 1.40 after 0.7.10 is kept",
+			binVersion: "0.8.0",
+			conf: func() *Config {
+				c := validConfig("0.7.10")
+				c.DockerConfig["default"].Version = "1.40"
+				return c
+			},
+			versions: map[string]string{"default": "1.40"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withVersion(t, tt.binVersion)
+			conf := tt.conf()
+			before := Flatten(conf, nil)
+			migrated, steps, err := PlanMigration(conf)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("PlanMigration() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PlanMigration() error = %+v", err)
+			}
+			if diff := diffEntries(before, Flatten(conf, nil)); len(diff) > 0 {
+				t.Errorf("PlanMigration() changed its argument: %v", diff)
+			}
+			var names []string
+			for _, s := range steps {
+				names = append(names, s.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.steps, ",") {
+				t.Errorf("steps = %v, want %v", names, tt.steps)
+			}
+			if tt.steps == nil {
+				if migrated != nil {
+					t.Errorf("migrated = %+v, want nil", migrated)
+				}
+				migrated = conf
+			} else if migrated.Version != strings.TrimPrefix(tt.binVersion, "v") {
+				t.Errorf("migrated version = %s, want %s", migrated.Version, tt.binVersion)
+			}
+			for name, want := range tt.versions {
+				if got := migrated.DockerConfig[name].Version; got != want {
+					t.Errorf("config.%s.version = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrationConfig(t *testing.T) {
+	withVersion(t, "0.8.0")
+	old := File
+	File = filepath.Join(t.TempDir(), "config.toml")
+	t.Cleanup(func() { File = old })
+
+	conf := validConfig("0.7.10")
+	conf.Image = ""
+	if err := conf.Save(); err != nil {
+		t.Fatal(err)
+	}
+	original, err := os.ReadFile(File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.MountDir = "/mnt/container/"
+	if err = MigrationConfig(conf); err == nil || !strings.Contains(err.Error(), "image is empty") {
+		t.Fatalf("MigrationConfig() error = %v, want invalid config", err)
+	}
+	if data, _ := os.ReadFile(File); string(data) != string(original) {
+		t.Errorf("invalid migration changed %s", File)
+	}
+
+	conf.Image = "nicolaka/netshoot:latest"
+	if err = MigrationConfig(conf); err != nil {
+		t.Fatalf("MigrationConfig() error = %+v", err)
+	}
+	backups, _ := filepath.Glob(File + ".*.bak")
+	if len(backups) != 1 {
+		t.Fatalf("backups = %v, want one", backups)
+	}
+	saved, _, err := ReadFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Version != "0.8.0" || saved.MountDir != "/mnt/container" {
+		t.Errorf("saved version %s mount_dir %s, want 0.8.0 /mnt/container", saved.Version, saved.MountDir)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		mutate   func(c *Config)
+		problems []string
+	}{
+		{name: "valid", mutate: func(c *Config) {}},
+		{
+			name: "all problems at once",
+			mutate: func(c *Config) {
+				c.Image = ""
+				c.MountDir = "mnt"
+				c.Timeout = 0
+				c.Pull = "sometimes"
+				c.DockerConfigDefault = "missing"
+			},
+			problems: []string{
+				"image is empty",
+				"mount_dir `mnt` is not an absolute path",
+				"timeout must be positive",
+				"pull `sometimes`",
+				"config_default `missing`",
+			},
+		},
+		{
+			name: "docker config",
+			mutate: func(c *Config) {
+				c.DockerConfig["bad"] = &DockerConfig{Host: "unix:///x.sock", Version: "latest", TLS: true}
+				c.DockerConfig["empty"] = &DockerConfig{}
+			},
+			problems: []string{
+				"config.bad.version `latest`",
+				"config.bad.cert_dir is empty with tls",
+				"config.empty.host is empty",
+			},
+		},
+		{
+			name: "profile",
+			mutate: func(c *Config) {
+				c.Profiles = map[string]*Profile{"p": {Pull: "nope", Rootfs: "overlay"}}
+			},
+			problems: []string{"profile.p.pull `nope`", "profile.p.rootfs `overlay`"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := validConfig("0.8.0")
+			tt.mutate(conf)
+			err := conf.Validate()
+			if len(tt.problems) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() = nil, want %v", tt.problems)
+			}
+			for _, p := range tt.problems {
+				if !strings.Contains(err.Error(), p) {
+					t.Errorf("Validate() error = %v, want %q", err, p)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package config
+
+import "strings"
+
+// Redacted placeholder of secrets in config output
+const Redacted = "******"
+
+// secretKeys last key segments of secret values
+var secretKeys = []string{"cert_password", "password", "identity_token"}
+
+func redact(s string) string {
+	if s == "" {
+		return s
+	}
+	return Redacted
+}
+
+// Redacted returns a copy of the docker config with the cert password hidden
+func (c DockerConfig) Redacted() *DockerConfig {
+	c.CertPassword = redact(c.CertPassword)
+	return &c
+}
+
+// Redacted returns a copy of the config with cert and registry passwords hidden
+func (c *Config) Redacted() (*Config, error) {
+	clone, err := cloneConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	for name, dc := range clone.DockerConfig {
+		clone.DockerConfig[name] = dc.Redacted()
+	}
+	for _, auth := range clone.Registries {
+		auth.Password = redact(auth.Password)
+		auth.IdentityToken = redact(auth.IdentityToken)
+	}
+	return clone, nil
+}
+
+// RedactEntries hide the values of secret keys
+func RedactEntries(entries []Entry) []Entry {
+	for i, e := range entries {
+		key := e.Key[strings.LastIndex(e.Key, ".")+1:]
+		for _, secret := range secretKeys {
+			if key == secret {
+				entries[i].Value = redact(e.Value)
+			}
+		}
+	}
+	return entries
+}
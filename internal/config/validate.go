@@ -0,0 +1,91 @@
+package config
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/zeromake/docker-debug/pkg/opts"
+)
+
+// apiVersionRegexp docker api version like 1.40
+var apiVersionRegexp = regexp.MustCompile(`^[0-9]+\.[0-9]+$`)
+
+var (
+	pullPolicies     = []string{"", "always", "missing", "never"}
+	rootfsStrategies = []string{"", "auto", "merged", "pid", "proc", "none"}
+)
+
+// ValidAPIVersion report whether v looks like a docker api version (1.40)
+func ValidAPIVersion(v string) bool {
+	return apiVersionRegexp.MatchString(v)
+}
+
+// Validate check the config schema, all problems are reported at once
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, errors.Errorf(format, args...).Error())
+	}
+	if c.Image == "" {
+		add("image is empty")
+	}
+	if c.MountDir != "" && !path.IsAbs(c.MountDir) {
+		add("mount_dir `%s` is not an absolute path", c.MountDir)
+	}
+	if c.Timeout <= 0 {
+		add("timeout must be positive")
+	}
+	if c.ReadTimeout < 0 {
+		add("read_timeout is negative")
+	}
+	if c.GCMaxAge < 0 {
+		add("gc_max_age is negative")
+	}
+	if !oneOf(c.Pull, pullPolicies) {
+		add("pull `%s` is not one of always|missing|never", c.Pull)
+	}
+	if _, ok := c.DockerConfig[c.DockerConfigDefault]; !ok {
+		add("config_default `%s` is not a docker config", c.DockerConfigDefault)
+	}
+	for name, dc := range c.DockerConfig {
+		if dc == nil || dc.Host == "" {
+			add("config.%s.host is empty", name)
+			continue
+		}
+		if _, err := opts.ValidateHost(dc.Host); err != nil {
+			add("config.%s.host: %s", name, err)
+		}
+		if !IsAutoVersion(dc.Version) && !ValidAPIVersion(dc.Version) {
+			add("config.%s.version `%s` is not auto or an api version like 1.40", name, dc.Version)
+		}
+		if dc.TLS && dc.CertDir == "" {
+			add("config.%s.cert_dir is empty with tls", name)
+		}
+	}
+	for name, p := range c.Profiles {
+		if p == nil {
+			continue
+		}
+		if !oneOf(p.Pull, pullPolicies) {
+			add("profile.%s.pull `%s` is not one of always|missing|never", name, p.Pull)
+		}
+		if !oneOf(p.Rootfs, rootfsStrategies) {
+			add("profile.%s.rootfs `%s` is not one of auto|merged|pid|proc|none", name, p.Rootfs)
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.Errorf("invalid config:\n  %s", strings.Join(problems, "\n  "))
+}
+
+func oneOf(s string, values []string) bool {
+	for _, v := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -11,6 +11,7 @@ func Up000710(conf *Config) error {
 		// 强制切换为 1.40
 		if c.Version == "" || c.Version == api.DefaultVersion {
 			c.Version = "1.40"
+			c.forcedVersion = true
 		}
 	}
 	return nil
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"github.com/blang/semver"
+)
+
+// Up000800 update version 0.8.0
+func Up000800(conf *Config) error {
+	for _, c := range conf.DockerConfig {
+		// versions left empty or forced by 0.7.10 negotiate now, pinned versions are kept
+		if c.Version == "" || c.forcedVersion {
+			c.Version = AutoVersion
+		}
+	}
+	return nil
+}
+
+func init() {
+	v, err := semver.Parse("0.8.0")
+	if err != nil {
+		return
+	}
+	migrationArr = append(migrationArr, &migration{
+		Up:      Up000800,
+		Version: v,
+	})
+}
@@ -0,0 +1,134 @@
+package connhelper
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// commandConn a net.Conn over the stdin and stdout of a command
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	stderr bytes.Buffer
+
+	closeOnce sync.Once
+	waitErr   error
+	waitDone  chan struct{}
+}
+
+// NewCommandConn start the command, it is not bound to ctx as the connection
+// outlives the dial (pooled http connections, hijacked exec streams)
+func NewCommandConn(ctx context.Context, name string, args ...string) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	c := &commandConn{waitDone: make(chan struct{})}
+	c.cmd = exec.Command(name, args...)
+	setPgid(c.cmd)
+	c.cmd.Stderr = &c.stderr
+	var err error
+	if c.stdin, err = c.cmd.StdinPipe(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if c.stdout, err = c.cmd.StdoutPipe(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err = c.cmd.Start(); err != nil {
+		return nil, errors.Wrapf(err, "start %s", name)
+	}
+	go func() {
+		c.waitErr = c.cmd.Wait()
+		close(c.waitDone)
+	}()
+	return c, nil
+}
+
+// exitError wraps io.EOF with the command stderr when it failed
+func (c *commandConn) exitError(err error) error {
+	if err != io.EOF {
+		return err
+	}
+	select {
+	case <-c.waitDone:
+	case <-time.After(time.Second):
+		return err
+	}
+	if c.waitErr == nil {
+		return err
+	}
+	return errors.Errorf("%s: %s: %s", strings.Join(c.cmd.Args, " "), c.waitErr, strings.TrimSpace(c.stderr.String()))
+}
+
+func (c *commandConn) Read(p []byte) (int, error) {
+	n, err := c.stdout.Read(p)
+	if err != nil {
+		err = c.exitError(err)
+	}
+	return n, err
+}
+
+func (c *commandConn) Write(p []byte) (int, error) {
+	return c.stdin.Write(p)
+}
+
+// CloseWrite half close used by hijacked connections
+func (c *commandConn) CloseWrite() error {
+	return c.stdin.Close()
+}
+
+// CloseRead half close used by hijacked connections
+func (c *commandConn) CloseRead() error {
+	return c.stdout.Close()
+}
+
+func (c *commandConn) Close() error {
+	c.closeOnce.Do(func() {
+		_ = c.stdin.Close()
+		select {
+		case <-c.waitDone:
+		case <-time.After(time.Second):
+			_ = c.cmd.Process.Kill()
+			<-c.waitDone
+		}
+	})
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr {
+	return dummyAddr{}
+}
+
+func (c *commandConn) RemoteAddr() net.Addr {
+	return dummyAddr{}
+}
+
+func (c *commandConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *commandConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *commandConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+type dummyAddr struct{}
+
+func (dummyAddr) Network() string {
+	return "dummy"
+}
+
+func (dummyAddr) String() string {
+	return "dummy"
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package connhelper
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setPgid keep the helper out of the terminal process group,
+// ctrl-c is handled by docker-debug which still needs the connection to clean up
+func setPgid(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
@@ -0,0 +1,10 @@
+//go:build windows
+// +build windows
+
+package connhelper
+
+import (
+	"os/exec"
+)
+
+func setPgid(cmd *exec.Cmd) {}
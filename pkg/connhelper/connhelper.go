@@ -0,0 +1,87 @@
+package connhelper
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ConnectionHelper dial the docker daemon through a helper command
+type ConnectionHelper struct {
+	Dialer func(ctx context.Context, network, addr string) (net.Conn, error)
+	// Host dummy http host for the docker client, the dialer ignores it
+	Host string
+}
+
+// SSHSpec parsed ssh://[user@]host[:port][/socket]
+type SSHSpec struct {
+	User string
+	Host string
+	Port string
+	// Path remote docker socket, empty for the remote default
+	Path string
+}
+
+// ParseSSHURL parse ssh://[user@]host[:port][/socket]
+func ParseSSHURL(daemonURL string) (*SSHSpec, error) {
+	u, err := url.Parse(daemonURL)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if u.Scheme != "ssh" {
+		return nil, errors.Errorf("invalid ssh host: `%s`", daemonURL)
+	}
+	sp := &SSHSpec{
+		User: u.User.Username(),
+		Host: u.Hostname(),
+		Port: u.Port(),
+		Path: strings.TrimSuffix(u.Path, "/"),
+	}
+	if _, ok := u.User.Password(); ok {
+		return nil, errors.Errorf("plain-text password is not supported in ssh host: `%s`, use keys or the agent", daemonURL)
+	}
+	if sp.Host == "" {
+		return nil, errors.Errorf("no host in ssh host: `%s`", daemonURL)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return nil, errors.Errorf("extra parts in ssh host: `%s`", daemonURL)
+	}
+	return sp, nil
+}
+
+// Args returns the ssh arguments running `docker system dial-stdio` on the remote host,
+// ~/.ssh/config and the agent are used by the ssh binary
+func (sp *SSHSpec) Args() []string {
+	args := []string{"-o", "ConnectTimeout=30", "-T"}
+	if sp.User != "" {
+		args = append(args, "-l", sp.User)
+	}
+	if sp.Port != "" {
+		args = append(args, "-p", sp.Port)
+	}
+	args = append(args, "--", sp.Host, "docker")
+	if sp.Path != "" {
+		args = append(args, "--host", "unix://"+sp.Path)
+	}
+	return append(args, "system", "dial-stdio")
+}
+
+// GetConnectionHelper returns nil when the host is not handled by a helper
+func GetConnectionHelper(daemonURL string) (*ConnectionHelper, error) {
+	if !strings.HasPrefix(daemonURL, "ssh://") {
+		return nil, nil
+	}
+	sp, err := ParseSSHURL(daemonURL)
+	if err != nil {
+		return nil, err
+	}
+	return &ConnectionHelper{
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return NewCommandConn(ctx, "ssh", sp.Args()...)
+		},
+		Host: "http://docker.example.com",
+	}, nil
+}
@@ -0,0 +1,155 @@
+package credentials
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types/registry"
+	"github.com/pkg/errors"
+)
+
+const (
+	// IndexServer docker hub server address used as key in the docker config
+	IndexServer = "https://index.docker.io/v1/"
+
+	configFileName = "config.json"
+	helperPrefix   = "docker-credential-"
+	// tokenUsername username returned by helpers when the secret is an identity token
+	tokenUsername = "<token>"
+)
+
+// File is the credentials part of the docker cli config.json
+type File struct {
+	Auths       map[string]AuthEntry `json:"auths"`
+	CredsStore  string               `json:"credsStore,omitempty"`
+	CredHelpers map[string]string    `json:"credHelpers,omitempty"`
+}
+
+// AuthEntry a registry entry of auths
+type AuthEntry struct {
+	Auth          string `json:"auth,omitempty"`
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
+
+type helperCredentials struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+// Dir returns the docker cli config dir, $DOCKER_CONFIG or ~/.docker
+func Dir() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".docker")
+}
+
+// Load read config.json in dir, a missing file is empty
+func Load(dir string) (*File, error) {
+	f := &File{}
+	data, err := os.ReadFile(filepath.Join(dir, configFileName))
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err = json.Unmarshal(data, f); err != nil {
+		return nil, errors.Wrapf(err, "parse %s", filepath.Join(dir, configFileName))
+	}
+	return f, nil
+}
+
+// ServerAddress returns the key of the registry domain in the docker config
+func ServerAddress(domain string) string {
+	switch domain {
+	case "docker.io", "index.docker.io", "registry-1.docker.io":
+		return IndexServer
+	}
+	return domain
+}
+
+// hostname strips scheme and path of a server address
+func hostname(address string) string {
+	address = strings.TrimPrefix(address, "https://")
+	address = strings.TrimPrefix(address, "http://")
+	host, _, _ := strings.Cut(address, "/")
+	return host
+}
+
+// Get resolve credentials of the registry domain from credHelpers, credsStore then auths
+func (f *File) Get(domain string) (registry.AuthConfig, error) {
+	server := ServerAddress(domain)
+	if helper, ok := f.CredHelpers[server]; ok && helper != "" {
+		return helperGet(helper, server)
+	}
+	if helper, ok := f.CredHelpers[domain]; ok && helper != "" {
+		return helperGet(helper, server)
+	}
+	if f.CredsStore != "" {
+		auth, err := helperGet(f.CredsStore, server)
+		if err != nil || auth.Username != "" || auth.IdentityToken != "" {
+			return auth, err
+		}
+	}
+	for key, entry := range f.Auths {
+		if key != server && hostname(key) != hostname(server) {
+			continue
+		}
+		auth := registry.AuthConfig{
+			Username:      entry.Username,
+			Password:      entry.Password,
+			IdentityToken: entry.IdentityToken,
+			ServerAddress: server,
+		}
+		if entry.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return auth, errors.Wrapf(err, "decode auth of %s", key)
+			}
+			auth.Username, auth.Password, _ = strings.Cut(string(decoded), ":")
+		}
+		return auth, nil
+	}
+	return registry.AuthConfig{ServerAddress: server}, nil
+}
+
+// helperGet run `docker-credential-<helper> get`, credentials not found are empty
+func helperGet(helper, server string) (registry.AuthConfig, error) {
+	auth := registry.AuthConfig{ServerAddress: server}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(helperPrefix+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stdout.String() + stderr.String())
+		if strings.Contains(msg, "credentials not found") {
+			return auth, nil
+		}
+		return auth, errors.Wrapf(err, "%s%s get: %s", helperPrefix, helper, msg)
+	}
+	var creds helperCredentials
+	if err := json.Unmarshal(stdout.Bytes(), &creds); err != nil {
+		return auth, errors.Wrapf(err, "%s%s get", helperPrefix, helper)
+	}
+	if creds.Username == tokenUsername {
+		auth.IdentityToken = creds.Secret
+	} else {
+		auth.Username = creds.Username
+		auth.Password = creds.Secret
+	}
+	return auth, nil
+}
@@ -0,0 +1,142 @@
+package dockercontext
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultName the implicit context of DOCKER_HOST, it has no metadata
+	DefaultName = "default"
+
+	contextsDir    = "contexts"
+	metaDir        = "meta"
+	tlsDir         = "tls"
+	metaFile       = "meta.json"
+	configFileName = "config.json"
+	dockerEndpoint = "docker"
+)
+
+// Context a docker cli context with its docker endpoint
+type Context struct {
+	Name          string
+	Description   string
+	Host          string
+	SkipTLSVerify bool
+	// TLSDir directory holding ca.pem, cert.pem and key.pem, empty without tls material
+	TLSDir string
+}
+
+type metadata struct {
+	Name     string
+	Metadata struct {
+		Description string `json:",omitempty"`
+	}
+	Endpoints map[string]struct {
+		Host          string `json:",omitempty"`
+		SkipTLSVerify bool
+	}
+}
+
+// contextDir contexts are stored by the sha256 of the name
+func contextDir(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:])
+}
+
+// List read all contexts in the docker config dir, sorted by name
+func List(dir string) ([]Context, error) {
+	root := filepath.Join(dir, contextsDir, metaDir)
+	entries, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	contexts := make([]Context, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		c, err := load(dir, entry.Name())
+		if os.IsNotExist(errors.Cause(err)) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		contexts = append(contexts, c)
+	}
+	sort.Slice(contexts, func(i, j int) bool {
+		return contexts[i].Name < contexts[j].Name
+	})
+	return contexts, nil
+}
+
+// Get read the named context
+func Get(dir, name string) (Context, error) {
+	c, err := load(dir, contextDir(name))
+	if os.IsNotExist(errors.Cause(err)) {
+		return c, errors.Errorf("docker context `%s` not found", name)
+	}
+	return c, err
+}
+
+func load(dir, id string) (Context, error) {
+	var c Context
+	file := filepath.Join(dir, contextsDir, metaDir, id, metaFile)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return c, errors.WithStack(err)
+	}
+	var meta metadata
+	if err = json.Unmarshal(data, &meta); err != nil {
+		return c, errors.Wrapf(err, "parse %s", file)
+	}
+	endpoint, ok := meta.Endpoints[dockerEndpoint]
+	if !ok {
+		return c, errors.Errorf("docker context `%s` has no docker endpoint", meta.Name)
+	}
+	c = Context{
+		Name:          meta.Name,
+		Description:   meta.Metadata.Description,
+		Host:          endpoint.Host,
+		SkipTLSVerify: endpoint.SkipTLSVerify,
+	}
+	certDir := filepath.Join(dir, contextsDir, tlsDir, id, dockerEndpoint)
+	if info, err := os.Stat(certDir); err == nil && info.IsDir() {
+		c.TLSDir = certDir
+	}
+	return c, nil
+}
+
+// Current returns DOCKER_CONTEXT or currentContext of config.json, default when unset
+func Current(dir string) (string, error) {
+	if name := os.Getenv("DOCKER_CONTEXT"); name != "" {
+		return name, nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, configFileName))
+	if os.IsNotExist(err) {
+		return DefaultName, nil
+	}
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	var conf struct {
+		CurrentContext string `json:"currentContext,omitempty"`
+	}
+	if err = json.Unmarshal(data, &conf); err != nil {
+		return "", errors.Wrapf(err, "parse %s", filepath.Join(dir, configFileName))
+	}
+	if conf.CurrentContext == "" {
+		return DefaultName, nil
+	}
+	return conf.CurrentContext, nil
+}
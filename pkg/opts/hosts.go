@@ -79,6 +79,10 @@ func parseDockerDaemonHost(addr string) (string, error) {
 	case "fd":
 		return addr, nil
 	case "ssh":
+		u, err := url.Parse(addr)
+		if err != nil || u.Hostname() == "" {
+			return "", errors.Errorf("Invalid ssh address format (expected ssh://[user@]host[:port]): %s", addr)
+		}
 		return addr, nil
 	default:
 		return "", errors.Errorf("Invalid bind address format: %s", addr)
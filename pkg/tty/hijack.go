@@ -30,6 +30,9 @@ type HijackedIOStreamer struct {
 
 	TTY        bool
 	DetachKeys string
+
+	// Recorder receives a copy of the output when set
+	Recorder *Recorder
 }
 
 // Stream handles setting up the IO and then begins streaming stdin/stdout
@@ -116,16 +119,26 @@ func (h *HijackedIOStreamer) beginOutputStream(restoreInput func()) <-chan error
 		return nil
 	}
 
+	outputStream, errorStream := h.OutputStream, h.ErrorStream
+	if h.Recorder != nil {
+		if outputStream != nil {
+			outputStream = io.MultiWriter(outputStream, h.Recorder)
+		}
+		if errorStream != nil {
+			errorStream = io.MultiWriter(errorStream, h.Recorder)
+		}
+	}
+
 	outputDone := make(chan error)
 	go func() {
 		var err error
 
 		// When TTY is ON, use regular copy
-		if h.OutputStream != nil && h.TTY {
-			_, err = io.Copy(h.OutputStream, h.Resp.Reader)
+		if outputStream != nil && h.TTY {
+			_, err = io.Copy(outputStream, h.Resp.Reader)
 			restoreInput()
 		} else {
-			_, err = stdcopy.StdCopy(h.OutputStream, h.ErrorStream, h.Resp.Reader)
+			_, err = stdcopy.StdCopy(outputStream, errorStream, h.Resp.Reader)
 		}
 
 		logrus.Debug("[hijack] End of stdout")
@@ -0,0 +1,164 @@
+package tty
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultRecordWidth and defaultRecordHeight are used when the output is not a terminal
+const (
+	defaultRecordWidth  = 80
+	defaultRecordHeight = 24
+)
+
+type recordHeader struct {
+	Version   int               `json:"version"`
+	Width     uint              `json:"width"`
+	Height    uint              `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Recorder writes a session as an asciicast v2 file
+type Recorder struct {
+	mu      sync.Mutex
+	file    *os.File
+	start   time.Time
+	height  uint
+	width   uint
+	pending []byte
+	// closed events after Close are dropped
+	closed bool
+	// failed a write error was logged, the session goes on without recording
+	failed bool
+}
+
+// NewRecorder create the cast file and write the asciicast v2 header
+func NewRecorder(filename string, height, width uint, title string) (*Recorder, error) {
+	if height == 0 || width == 0 {
+		height, width = defaultRecordHeight, defaultRecordWidth
+	}
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	r := &Recorder{
+		file:   file,
+		start:  time.Now(),
+		height: height,
+		width:  width,
+	}
+	header := recordHeader{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: r.start.Unix(),
+		Title:     title,
+		Env: map[string]string{
+			"SHELL": os.Getenv("SHELL"),
+			"TERM":  os.Getenv("TERM"),
+		},
+	}
+	if err = r.writeLine(header); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write record output event, incomplete utf-8 sequences are held until the next write,
+// it never fails so a recording error does not abort the session output
+func (r *Recorder) Write(p []byte) (int, error) {
+	if r == nil {
+		return len(p), nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.failed {
+		return len(p), nil
+	}
+	data := append(r.pending, p...)
+	n := completeUTF8(data)
+	r.pending = append([]byte(nil), data[n:]...)
+	if n == 0 {
+		return len(p), nil
+	}
+	r.check(r.event("o", string(data[:n])))
+	return len(p), nil
+}
+
+// Resize record resize event when the terminal size changed
+func (r *Recorder) Resize(height, width uint) {
+	if r == nil || height == 0 || width == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.failed || r.height == height && r.width == width {
+		return
+	}
+	r.height, r.width = height, width
+	r.check(r.event("r", fmt.Sprintf("%dx%d", width, height)))
+}
+
+// Close flush pending output and close the cast file
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if len(r.pending) > 0 && !r.failed {
+		r.check(r.event("o", string(r.pending)))
+		r.pending = nil
+	}
+	return errors.WithStack(r.file.Close())
+}
+
+// check log the first recording error, later events are dropped
+func (r *Recorder) check(err error) {
+	if err == nil {
+		return
+	}
+	r.failed = true
+	logrus.Warnf("recording to %s stopped: %s", r.file.Name(), err)
+}
+
+func (r *Recorder) event(code, data string) error {
+	elapsed := time.Since(r.start).Seconds()
+	return r.writeLine([]interface{}{elapsed, code, data})
+}
+
+func (r *Recorder) writeLine(v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = r.file.Write(append(line, '\n'))
+	return errors.WithStack(err)
+}
+
+// completeUTF8 returns the length of p without a trailing incomplete rune
+func completeUTF8(p []byte) int {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if !utf8.FullRune(p[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(p)
+}
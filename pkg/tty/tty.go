@@ -39,10 +39,12 @@ func ResizeTtyTo(ctx context.Context, client client.ContainerAPIClient, id strin
 }
 
 // MonitorTtySize updates the container tty size when the terminal tty changes size
-func MonitorTtySize(ctx context.Context, client client.ContainerAPIClient, out *stream.OutStream, id string, isExec bool) error {
+// until ctx is done, the new size is also recorded when recorder is not nil
+func MonitorTtySize(ctx context.Context, client client.ContainerAPIClient, out *stream.OutStream, id string, isExec bool, recorder *Recorder) error {
 	resizeTty := func() {
 		height, width := out.GetTtySize()
 		ResizeTtyTo(ctx, client, id, height, width, isExec)
+		recorder.Resize(height, width)
 	}
 
 	resizeTty()
@@ -51,7 +53,11 @@ func MonitorTtySize(ctx context.Context, client client.ContainerAPIClient, out *
 		go func() {
 			prevH, prevW := out.GetTtySize()
 			for {
-				time.Sleep(time.Millisecond * 250)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(time.Millisecond * 250):
+				}
 				h, w := out.GetTtySize()
 
 				if prevW != w || prevH != h {
@@ -65,8 +71,14 @@ func MonitorTtySize(ctx context.Context, client client.ContainerAPIClient, out *
 		sigChan := make(chan os.Signal, 1)
 		goSignal.Notify(sigChan, syscall.Signal(0x1c))
 		go func() {
-			for range sigChan {
-				resizeTty()
+			defer goSignal.Stop(sigChan)
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-sigChan:
+					resizeTty()
+				}
 			}
 		}()
 	}